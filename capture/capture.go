@@ -0,0 +1,568 @@
+// Captures SQL statements sent to PostgreSQL and CockroachDB over the wire protocol
+// by an in-process proxy placed between the client and the database.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/rewrite"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+const (
+	listenAddress = "127.0.0.1:0"
+
+	// Codes of the requests sent instead of the startup message
+	cancelRequestCode = 80877102
+	sslRequestCode    = 80877103
+	gssEncRequestCode = 80877104
+
+	lengthSize       = 4
+	maxMessageLength = 1 << 30
+
+	// Response to the encryption requests that refuses encryption
+	encryptionRefused = 'N'
+
+	// Error of the queries interrupted by the closing of the connection
+	connectionClosedMessage = "connection closed"
+)
+
+const (
+	msgBind               = 'B'
+	msgCommandComplete    = 'C'
+	msgEmptyQueryResponse = 'I'
+	msgErrorResponse      = 'E'
+	msgExecute            = 'E'
+	msgParse              = 'P'
+	msgPortalSuspended    = 's'
+	msgQuery              = 'Q'
+	msgReadyForQuery      = 'Z'
+)
+
+var (
+	ErrMessageLengthInvalid = errors.New("message length is invalid")
+	ErrCancelRequest        = errors.New("cancel request was forwarded")
+)
+
+// Parameter of the extended query.
+type Param struct {
+	// Value as it is sent by the client. Format of the binary values depends on the
+	// parameter type
+	Value  []byte
+	Binary bool
+	Null   bool
+}
+
+// Returns text value of the parameter, hex representation of the binary one or
+// NULL.
+func (param Param) String() string {
+	switch {
+	case param.Null:
+		return "NULL"
+	case param.Binary:
+		return `\x` + hex.EncodeToString(param.Value)
+	}
+
+	return string(param.Value)
+}
+
+// Query sent by the client.
+type Query struct {
+	// Identifier of the client connection, see [Proxy.Connections]
+	Conn int
+	// Text of the query. Simple query can contain several statements
+	SQL string
+	// Parameters of the extended query
+	Params []Param
+	// Query was sent using the extended query protocol
+	Extended bool
+	// Time at which the query was sent to the database
+	Start time.Time
+	// Time elapsed until the query was completed by the database
+	Duration time.Duration
+	// Message of the error returned by the database
+	Err string
+}
+
+// Client connection.
+type Connection struct {
+	ID              int
+	User            string
+	Database        string
+	ApplicationName string
+	Start           time.Time
+}
+
+// Proxy that records queries sent through it.
+type Proxy struct {
+	listener net.Listener
+	upstream url.URL
+
+	mutex       sync.Mutex
+	active      map[int]net.Conn
+	closed      bool
+	connections []Connection
+	queries     []Query
+	lastID      int
+
+	wg sync.WaitGroup
+}
+
+// Starts proxy on the loopback interface that forwards connections to the host
+// specified in the DSN.
+//
+// Proxy refuses encryption requested by the clients and connects to the database
+// without encryption, so it can only be used with the DSNs with sslmode=disable,
+// e.g. returned by the psql and crdb packages with default options.
+//
+// [Proxy.Close] method must be called when the proxy is no longer needed if
+// [Start] did not return an error.
+func Start(upstream url.URL) (*Proxy, error) {
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	prx := &Proxy{
+		listener: listener,
+		upstream: upstream,
+		active:   make(map[int]net.Conn),
+	}
+
+	prx.wg.Add(1)
+
+	go prx.accept()
+
+	return prx, nil
+}
+
+// Returns DSN in which the address of the database is replaced by the address of
+// the proxy.
+func (prx *Proxy) DSN() url.URL {
+	return rewrite.Address(prx.upstream, prx.listener.Addr().String())
+}
+
+// Returns queries in the order of their completion. Queries interrupted by the
+// closing of the connection are completed with an error.
+func (prx *Proxy) Queries() []Query {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	return slices.Clone(prx.queries)
+}
+
+// Returns client connections in the order of their establishment.
+func (prx *Proxy) Connections() []Connection {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	return slices.Clone(prx.connections)
+}
+
+// Clears recorded queries and connections.
+func (prx *Proxy) Reset() {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	prx.queries = nil
+	prx.connections = nil
+}
+
+// Stops accepting connections and closes the established ones.
+func (prx *Proxy) Close() error {
+	err := prx.listener.Close()
+
+	prx.mutex.Lock()
+
+	prx.closed = true
+
+	for _, conn := range prx.active {
+		conn.Close()
+	}
+
+	prx.mutex.Unlock()
+
+	prx.wg.Wait()
+
+	return err
+}
+
+func (prx *Proxy) accept() {
+	defer prx.wg.Done()
+
+	for {
+		client, err := prx.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		id, added := prx.add(client)
+		if !added {
+			client.Close()
+			return
+		}
+
+		prx.wg.Add(1)
+
+		go func() {
+			defer prx.wg.Done()
+			defer prx.release(id)
+
+			prx.serve(id, client)
+		}()
+	}
+}
+
+func (prx *Proxy) add(client net.Conn) (int, bool) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	if prx.closed {
+		return 0, false
+	}
+
+	prx.lastID++
+	prx.active[prx.lastID] = client
+
+	return prx.lastID, true
+}
+
+func (prx *Proxy) release(id int) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	delete(prx.active, id)
+}
+
+func (prx *Proxy) record(query Query) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	prx.queries = append(prx.queries, query)
+}
+
+func (prx *Proxy) connected(conn Connection) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	prx.connections = append(prx.connections, conn)
+}
+
+func (prx *Proxy) serve(id int, client net.Conn) {
+	defer client.Close()
+
+	server, err := net.Dial("tcp", prx.upstream.Host)
+	if err != nil {
+		return
+	}
+
+	defer server.Close()
+
+	sess := &session{
+		id:         id,
+		proxy:      prx,
+		statements: make(map[string]string),
+		portals:    make(map[string]Query),
+	}
+
+	clientReader := bufio.NewReader(client)
+
+	if err := sess.startup(clientReader, client, server); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// Closing of the connections interrupts the other direction
+		defer client.Close()
+		defer server.Close()
+
+		_ = forward(bufio.NewReader(server), client, sess.observeServer)
+	}()
+
+	_ = forward(clientReader, server, sess.observeClient)
+
+	client.Close()
+	server.Close()
+
+	<-done
+
+	sess.interrupt()
+}
+
+// Forwards messages and passes them to the observer.
+func forward(src *bufio.Reader, dst io.Writer, observe func(kind byte, body []byte)) error {
+	for {
+		kind, raw, err := readMessage(src)
+		if err != nil {
+			return err
+		}
+
+		observe(kind, raw[1+lengthSize:])
+
+		if _, err := dst.Write(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// Reads typed message and returns its type and the message as is.
+func readMessage(src *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 1+lengthSize)
+
+	if _, err := io.ReadFull(src, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < lengthSize || length > maxMessageLength {
+		return 0, nil, fmt.Errorf("%w: %d", ErrMessageLengthInvalid, length)
+	}
+
+	raw := make([]byte, 1+int(length))
+	copy(raw, header)
+
+	if _, err := io.ReadFull(src, raw[len(header):]); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], raw, nil
+}
+
+// Reads untyped startup packet and returns it as is.
+func readStartup(src *bufio.Reader) ([]byte, error) {
+	header := make([]byte, lengthSize)
+
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length < 2*lengthSize || length > maxMessageLength {
+		return nil, fmt.Errorf("%w: %d", ErrMessageLengthInvalid, length)
+	}
+
+	raw := make([]byte, int(length))
+	copy(raw, header)
+
+	if _, err := io.ReadFull(src, raw[len(header):]); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// State of the protocol of the client connection.
+type session struct {
+	id    int
+	proxy *Proxy
+
+	mutex      sync.Mutex
+	statements map[string]string
+	portals    map[string]Query
+	pending    []Query
+}
+
+func (sess *session) startup(src *bufio.Reader, client net.Conn, server net.Conn) error {
+	for {
+		raw, err := readStartup(src)
+		if err != nil {
+			return err
+		}
+
+		switch binary.BigEndian.Uint32(raw[lengthSize:]) {
+		case sslRequestCode, gssEncRequestCode:
+			if _, err := client.Write([]byte{encryptionRefused}); err != nil {
+				return err
+			}
+
+			continue
+		case cancelRequestCode:
+			if _, err := server.Write(raw); err != nil {
+				return err
+			}
+
+			return ErrCancelRequest
+		}
+
+		var msg pgproto3.StartupMessage
+
+		if err := msg.Decode(raw[lengthSize:]); err != nil {
+			return err
+		}
+
+		conn := Connection{
+			ID:              sess.id,
+			User:            msg.Parameters["user"],
+			Database:        msg.Parameters["database"],
+			ApplicationName: msg.Parameters["application_name"],
+			Start:           time.Now(),
+		}
+
+		sess.proxy.connected(conn)
+
+		_, err = server.Write(raw)
+
+		return err
+	}
+}
+
+func (sess *session) observeClient(kind byte, body []byte) {
+	sess.mutex.Lock()
+	defer sess.mutex.Unlock()
+
+	switch kind {
+	case msgQuery:
+		var msg pgproto3.Query
+
+		if err := msg.Decode(body); err != nil {
+			return
+		}
+
+		query := Query{
+			Conn:  sess.id,
+			SQL:   msg.String,
+			Start: time.Now(),
+		}
+
+		sess.pending = append(sess.pending, query)
+	case msgParse:
+		var msg pgproto3.Parse
+
+		if err := msg.Decode(body); err != nil {
+			return
+		}
+
+		sess.statements[msg.Name] = msg.Query
+	case msgBind:
+		var msg pgproto3.Bind
+
+		if err := msg.Decode(body); err != nil {
+			return
+		}
+
+		query := Query{
+			Conn:     sess.id,
+			SQL:      sess.statements[msg.PreparedStatement],
+			Params:   prepareParams(msg),
+			Extended: true,
+		}
+
+		sess.portals[msg.DestinationPortal] = query
+	case msgExecute:
+		var msg pgproto3.Execute
+
+		if err := msg.Decode(body); err != nil {
+			return
+		}
+
+		query := sess.portals[msg.Portal]
+		query.Start = time.Now()
+
+		sess.pending = append(sess.pending, query)
+	}
+}
+
+func (sess *session) observeServer(kind byte, body []byte) {
+	sess.mutex.Lock()
+	defer sess.mutex.Unlock()
+
+	if len(sess.pending) == 0 {
+		return
+	}
+
+	head := &sess.pending[0]
+
+	switch kind {
+	case msgCommandComplete, msgEmptyQueryResponse, msgPortalSuspended:
+		// Simple query can contain several statements, so it is completed by the
+		// ReadyForQuery message
+		if head.Extended {
+			sess.complete(1)
+		}
+	case msgErrorResponse:
+		var msg pgproto3.ErrorResponse
+
+		if err := msg.Decode(body); err != nil {
+			return
+		}
+
+		head.Err = msg.Message
+
+		if head.Extended {
+			sess.complete(1)
+		}
+	case msgReadyForQuery:
+		// Extended queries that are not completed were skipped by the database due
+		// to an error
+		sess.complete(len(sess.pending))
+	}
+}
+
+func (sess *session) complete(quantity int) {
+	now := time.Now()
+
+	for _, query := range sess.pending[:quantity] {
+		query.Duration = now.Sub(query.Start)
+		sess.proxy.record(query)
+	}
+
+	sess.pending = sess.pending[quantity:]
+}
+
+func (sess *session) interrupt() {
+	sess.mutex.Lock()
+	defer sess.mutex.Unlock()
+
+	for id := range sess.pending {
+		if sess.pending[id].Err == "" {
+			sess.pending[id].Err = connectionClosedMessage
+		}
+	}
+
+	sess.complete(len(sess.pending))
+}
+
+func prepareParams(msg pgproto3.Bind) []Param {
+	params := make([]Param, len(msg.Parameters))
+
+	for id, value := range msg.Parameters {
+		params[id] = Param{
+			Value:  value,
+			Binary: paramFormat(msg.ParameterFormatCodes, id) == pgproto3.BinaryFormat,
+			Null:   value == nil,
+		}
+	}
+
+	return params
+}
+
+// Format codes can be specified for all parameters at once or for each parameter.
+func paramFormat(codes []int16, id int) int16 {
+	switch len(codes) {
+	case 0:
+		return pgproto3.TextFormat
+	case 1:
+		return codes[0]
+	}
+
+	if id < len(codes) {
+		return codes[id]
+	}
+
+	return pgproto3.TextFormat
+}
@@ -0,0 +1,258 @@
+package capture
+
+import (
+	"database/sql"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/psql"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	cleanup := interceptor.Prepare()
+	defer cleanup()
+
+	m.Run()
+}
+
+func TestProxy(t *testing.T) {
+	upstream := runFakeServer(t)
+
+	prx, err := Start(upstream)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	dsn := prx.DSN()
+	require.NotEqual(t, upstream.Host, dsn.Host)
+
+	conn, err := pgconn.Connect(t.Context(), dsn.String())
+	require.NoError(t, err)
+
+	_, err = conn.Exec(t.Context(), "SELECT 1; SELECT 2").ReadAll()
+	require.NoError(t, err)
+
+	_, err = conn.Exec(t.Context(), "SELECT fail").ReadAll()
+	require.Error(t, err)
+
+	result := conn.ExecParams(
+		t.Context(),
+		"SELECT $1, $2, $3",
+		[][]byte{[]byte("text"), {0, 0, 0, 1}, nil},
+		nil,
+		[]int16{pgproto3.TextFormat, pgproto3.BinaryFormat, pgproto3.TextFormat},
+		nil,
+	).Read()
+	require.NoError(t, result.Err)
+
+	_, err = conn.Prepare(t.Context(), "named", "UPDATE items SET value = $1", nil)
+	require.NoError(t, err)
+
+	result = conn.ExecPrepared(t.Context(), "named", [][]byte{[]byte("2")}, nil, nil).Read()
+	require.NoError(t, result.Err)
+
+	require.NoError(t, conn.Close(t.Context()))
+
+	connections := prx.Connections()
+	require.Len(t, connections, 1)
+	require.Equal(t, "user", connections[0].User)
+	require.Equal(t, "database", connections[0].Database)
+
+	queries := prx.Queries()
+	require.Len(t, queries, 4)
+
+	require.Equal(t, "SELECT 1; SELECT 2", queries[0].SQL)
+	require.False(t, queries[0].Extended)
+	require.Empty(t, queries[0].Err)
+	require.Equal(t, connections[0].ID, queries[0].Conn)
+	require.False(t, queries[0].Start.IsZero())
+
+	require.Equal(t, "SELECT fail", queries[1].SQL)
+	require.Equal(t, "failed", queries[1].Err)
+
+	require.Equal(t, "SELECT $1, $2, $3", queries[2].SQL)
+	require.True(t, queries[2].Extended)
+	require.Equal(
+		t,
+		[]string{"text", `\x00000001`, "NULL"},
+		[]string{
+			queries[2].Params[0].String(),
+			queries[2].Params[1].String(),
+			queries[2].Params[2].String(),
+		},
+	)
+
+	require.Equal(t, "UPDATE items SET value = $1", queries[3].SQL)
+	require.Equal(t, []Param{{Value: []byte("2")}}, queries[3].Params)
+
+	prx.Reset()
+	require.Empty(t, prx.Queries())
+	require.Empty(t, prx.Connections())
+}
+
+func TestProxyCloseActive(t *testing.T) {
+	upstream := runFakeServer(t)
+
+	prx, err := Start(upstream)
+	require.NoError(t, err)
+
+	dsn := prx.DSN()
+
+	conn, err := pgconn.Connect(t.Context(), dsn.String())
+	require.NoError(t, err)
+
+	require.NoError(t, prx.Close())
+
+	_, err = conn.Exec(t.Context(), "SELECT 1").ReadAll()
+	require.Error(t, err)
+}
+
+func TestProxyPsql(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := psql.Run(t.Context(), "17", "postgres")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	prx, err := Start(dsns[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	dsn := prx.DSN()
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	var value int
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT $1::integer", 42).Scan(&value))
+	require.Equal(t, 42, value)
+
+	require.NoError(t, db.Close())
+
+	found := false
+
+	for _, query := range prx.Queries() {
+		if query.SQL == "SELECT $1::integer" {
+			require.Len(t, query.Params, 1)
+			require.Empty(t, query.Err)
+
+			found = true
+		}
+	}
+
+	require.True(t, found)
+}
+
+// Runs server that implements the minimal subset of the protocol.
+func runFakeServer(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, listener.Close())
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveFake(conn)
+		}
+	}()
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword("user", "password"),
+		Host:     listener.Addr().String(),
+		Path:     "/database",
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn
+}
+
+func serveFake(conn net.Conn) {
+	defer conn.Close()
+
+	backend := pgproto3.NewBackend(conn, conn)
+
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			if strings.Contains(msg.String, "fail") {
+				backend.Send(
+					&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42000", Message: "failed"},
+				)
+			} else {
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")})
+			}
+
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Parse:
+			backend.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Bind:
+			backend.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Describe:
+			if msg.ObjectType == 'S' {
+				backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: []uint32{25}})
+			}
+
+			backend.Send(&pgproto3.NoData{})
+		case *pgproto3.Execute:
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("UPDATE 1")})
+		case *pgproto3.Sync:
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
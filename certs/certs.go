@@ -0,0 +1,195 @@
+// Creates x509 certificates and keys intended for use in tests.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+)
+
+var (
+	ErrCommonNameEmpty  = errors.New("common name is not specified")
+	ErrLifetimeNegative = errors.New("lifetime is negative")
+)
+
+const (
+	// Lifetime of certificates used when it is not specified.
+	DefaultLifetime = 24 * time.Hour
+
+	serialNumberBitLength = 128
+
+	// Shifts the beginning of the validity period to the past to allow for
+	// some clock skew between the test host and the containers.
+	notBeforeShift = time.Hour
+)
+
+const (
+	blockTypeCertificate = "CERTIFICATE"
+	blockTypePrivateKey  = "PRIVATE KEY"
+)
+
+// Certificate and private key in PEM format.
+type Pair struct {
+	Cert []byte
+	Key  []byte
+}
+
+// Certificate authority that issues server and client certificates.
+type Authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pair Pair
+
+	lifetime time.Duration
+}
+
+// Creates a self-signed certificate authority. If lifetime is zero the
+// [DefaultLifetime] is used.
+func NewAuthority(commonName string, lifetime time.Duration) (*Authority, error) {
+	if commonName == "" {
+		return nil, ErrCommonNameEmpty
+	}
+
+	if lifetime == 0 {
+		lifetime = DefaultLifetime
+	}
+
+	if lifetime < 0 {
+		return nil, ErrLifetimeNegative
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := prepareTemplate(commonName, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := encode(der, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &Authority{
+		cert:     cert,
+		key:      key,
+		pair:     pair,
+		lifetime: lifetime,
+	}
+
+	return ca, nil
+}
+
+// Returns certificate and private key of the certificate authority.
+func (ca *Authority) Pair() Pair {
+	return ca.pair
+}
+
+// Issues a server certificate. Hosts can be specified as DNS names or IP addresses.
+func (ca *Authority) IssueServer(commonName string, hosts ...string) (Pair, error) {
+	return ca.issue(commonName, x509.ExtKeyUsageServerAuth, hosts)
+}
+
+// Issues a client certificate.
+func (ca *Authority) IssueClient(commonName string) (Pair, error) {
+	return ca.issue(commonName, x509.ExtKeyUsageClientAuth, nil)
+}
+
+func (ca *Authority) issue(
+	commonName string,
+	usage x509.ExtKeyUsage,
+	hosts []string,
+) (Pair, error) {
+	if commonName == "" {
+		return Pair{}, ErrCommonNameEmpty
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	template, err := prepareTemplate(commonName, ca.lifetime)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+			continue
+		}
+
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	return encode(der, key)
+}
+
+func prepareTemplate(commonName string, lifetime time.Duration) (*x509.Certificate, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialNumberBitLength)
+
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore: now.Add(-notBeforeShift),
+		NotAfter:  now.Add(lifetime),
+	}
+
+	return template, nil
+}
+
+func encode(der []byte, key *ecdsa.PrivateKey) (Pair, error) {
+	marshaled, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	pair := Pair{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: blockTypeCertificate, Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: blockTypePrivateKey, Bytes: marshaled}),
+	}
+
+	return pair, nil
+}
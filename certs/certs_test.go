@@ -0,0 +1,98 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthority(t *testing.T) {
+	ca, err := NewAuthority("ca", 0)
+	require.NoError(t, err)
+
+	server, err := ca.IssueServer("server", "localhost", "127.0.0.1", "node")
+	require.NoError(t, err)
+
+	client, err := ca.IssueClient("postgres")
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca.Pair().Cert))
+
+	serverCert := parse(t, server)
+
+	for _, host := range []string{"localhost", "127.0.0.1", "node"} {
+		opts := x509.VerifyOptions{
+			DNSName:   host,
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+
+		_, err = serverCert.Verify(opts)
+		require.NoError(t, err)
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:   "unknown",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	_, err = serverCert.Verify(opts)
+	require.Error(t, err)
+
+	clientCert := parse(t, client)
+	require.Equal(t, "postgres", clientCert.Subject.CommonName)
+
+	opts = x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	_, err = clientCert.Verify(opts)
+	require.NoError(t, err)
+
+	opts = x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	_, err = clientCert.Verify(opts)
+	require.Error(t, err)
+}
+
+func TestAuthorityWrongArgs(t *testing.T) {
+	ca, err := NewAuthority("", 0)
+	require.ErrorIs(t, err, ErrCommonNameEmpty)
+	require.Nil(t, ca)
+
+	ca, err = NewAuthority("ca", -time.Second)
+	require.ErrorIs(t, err, ErrLifetimeNegative)
+	require.Nil(t, ca)
+
+	ca, err = NewAuthority("ca", time.Hour)
+	require.NoError(t, err)
+
+	_, err = ca.IssueServer("")
+	require.Error(t, err)
+
+	_, err = ca.IssueClient("")
+	require.Error(t, err)
+}
+
+func parse(t *testing.T, pair Pair) *x509.Certificate {
+	_, err := tls.X509KeyPair(pair.Cert, pair.Key)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(pair.Cert)
+	require.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	return cert
+}
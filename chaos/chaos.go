@@ -0,0 +1,360 @@
+// Runs a randomized but reproducible schedule of faults against databases.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/env"
+)
+
+var (
+	ErrFaultsEmpty         = errors.New("faults are not specified")
+	ErrFaultInvalid        = errors.New("fault name is empty or inject function is nil")
+	ErrDurationNotPositive = errors.New("duration is zero or negative")
+	ErrIntervalInvalid     = errors.New("interval is negative or its minimum exceeds maximum")
+	ErrSeedInvalid         = errors.New("seed from environment is invalid")
+	ErrFaultNotInjected    = errors.New("fault was not injected")
+	ErrFaultNotHealed      = errors.New("fault was not healed")
+)
+
+const (
+	defaultMinHold = time.Second
+	defaultMaxHold = 5 * time.Second
+	defaultMinRest = time.Second
+	defaultMaxRest = 5 * time.Second
+)
+
+// Actions of the runner recorded in events.
+const (
+	ActionInject = "inject"
+	ActionHeal   = "heal"
+)
+
+// Fault that can be injected by the runner.
+type Fault struct {
+	Name string
+	// Injects the fault. Random number generator must be used for all random
+	// decisions, e.g. for choosing the node, so the schedule is reproducible
+	Inject func(ctx context.Context, rnd *rand.Rand) (Injected, error)
+}
+
+// Result of the fault injection.
+type Injected struct {
+	// Description of the injected fault, e.g. affected node
+	Detail string
+	// Heals the injected fault. Context passed to the function is never canceled
+	// by the runner
+	Heal func(ctx context.Context) error
+}
+
+// Event of the schedule.
+type Event struct {
+	Time   time.Time
+	Action string
+	Fault  string
+	Detail string
+	Err    error
+}
+
+func (event Event) String() string {
+	text := event.Time.Format(time.RFC3339Nano) + " " + event.Action + " " + event.Fault
+
+	if event.Detail != "" {
+		text += ": " + event.Detail
+	}
+
+	if event.Err != nil {
+		text += ": " + event.Err.Error()
+	}
+
+	return text
+}
+
+// Options of the runner.
+type Opts struct {
+	// Seed of the schedule. If zero, seed is taken from the ILLUSION_CHAOS_SEED
+	// environment variable and, if it is not set, it is generated randomly
+	Seed uint64
+	// Duration of the schedule after which no new faults are injected
+	Duration time.Duration
+	// Range of the time during which the injected fault is held. By default
+	// [1s, 5s] is used
+	MinHold time.Duration
+	MaxHold time.Duration
+	// Range of the time between healing of the fault and injection of the next one.
+	// By default [1s, 5s] is used
+	MinRest time.Duration
+	MaxRest time.Duration
+	// Logs events of the schedule, e.g. t.Logf. By default events are not logged
+	Logf func(format string, args ...any)
+}
+
+func (opts Opts) normalize() Opts {
+	if opts.MinHold == 0 && opts.MaxHold == 0 {
+		opts.MinHold = defaultMinHold
+		opts.MaxHold = defaultMaxHold
+	}
+
+	if opts.MinRest == 0 && opts.MaxRest == 0 {
+		opts.MinRest = defaultMinRest
+		opts.MaxRest = defaultMaxRest
+	}
+
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+
+	return opts
+}
+
+func (opts Opts) validate() error {
+	if opts.Duration <= 0 {
+		return ErrDurationNotPositive
+	}
+
+	if opts.MinHold < 0 || opts.MinHold > opts.MaxHold {
+		return fmt.Errorf("%w: hold", ErrIntervalInvalid)
+	}
+
+	if opts.MinRest < 0 || opts.MinRest > opts.MaxRest {
+		return fmt.Errorf("%w: rest", ErrIntervalInvalid)
+	}
+
+	return nil
+}
+
+// Runs the schedule of faults in the background.
+type Runner struct {
+	faults []Fault
+	opts   Opts
+	seed   uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	err    error
+	events []Event
+	mutex  sync.Mutex
+}
+
+// Starts the schedule of faults. At each step a random fault is injected, held for
+// a random time and healed, then the runner rests for a random time. Only one
+// fault is injected at a time. Sequence of the faults, affected nodes and timings
+// are determined by the seed.
+//
+// Schedule stops when its duration elapses, the context is canceled or a fault
+// fails to be injected or healed. The injected fault is always healed when the
+// schedule stops.
+//
+// [Runner.Stop] or [Runner.Wait] method must be called if [Start] did not return
+// an error.
+func Start(ctx context.Context, opts Opts, faults ...Fault) (*Runner, error) {
+	if len(faults) == 0 {
+		return nil, ErrFaultsEmpty
+	}
+
+	for _, fault := range faults {
+		if fault.Name == "" || fault.Inject == nil {
+			return nil, ErrFaultInvalid
+		}
+	}
+
+	opts = opts.normalize()
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	seed, err := prepareSeed(opts.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+
+	rnr := &Runner{
+		faults: slices.Clone(faults),
+		opts:   opts,
+		seed:   seed,
+
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	opts.Logf("chaos: seed %d", seed)
+
+	go rnr.loop(ctx)
+
+	return rnr, nil
+}
+
+// Returns the seed of the schedule. Schedule can be replayed by setting the seed
+// in [Opts] or in the ILLUSION_CHAOS_SEED environment variable.
+func (rnr *Runner) Seed() uint64 {
+	return rnr.seed
+}
+
+// Waits for the end of the schedule and returns errors of the fault injection and
+// healing.
+func (rnr *Runner) Wait() error {
+	<-rnr.done
+
+	rnr.cancel()
+
+	rnr.mutex.Lock()
+	defer rnr.mutex.Unlock()
+
+	return rnr.err
+}
+
+// Stops the schedule, waits for the injected fault to be healed and returns errors
+// of the fault injection and healing.
+func (rnr *Runner) Stop() error {
+	rnr.cancel()
+	return rnr.Wait()
+}
+
+// Returns events of the schedule that occurred so far.
+func (rnr *Runner) Events() []Event {
+	rnr.mutex.Lock()
+	defer rnr.mutex.Unlock()
+
+	return slices.Clone(rnr.events)
+}
+
+// Logs the seed of the schedule if the test has failed, so the schedule can be
+// replayed. Must be called right after [Start].
+func (rnr *Runner) LogSeedOnFailure(t TB) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("chaos: schedule can be replayed with %s=%d", env.ChaosSeed, rnr.seed)
+		}
+	})
+}
+
+// Subset of the [testing.TB] interface.
+type TB interface {
+	Cleanup(fn func())
+	Failed() bool
+	Logf(format string, args ...any)
+}
+
+func (rnr *Runner) loop(ctx context.Context) {
+	defer close(rnr.done)
+
+	//nolint:gosec // Cryptographic strength is not needed
+	rnd := rand.New(rand.NewPCG(rnr.seed, rnr.seed))
+
+	for {
+		if !sleep(ctx, randomDuration(rnd, rnr.opts.MinRest, rnr.opts.MaxRest)) {
+			return
+		}
+
+		fault := rnr.faults[rnd.IntN(len(rnr.faults))]
+		hold := randomDuration(rnd, rnr.opts.MinHold, rnr.opts.MaxHold)
+
+		injected, err := fault.Inject(ctx, rnd)
+
+		rnr.record(ActionInject, fault.Name, injected.Detail, err)
+
+		if err != nil {
+			// Injection is interrupted by the end of the schedule
+			if ctx.Err() == nil {
+				rnr.fail(fmt.Errorf("%w: %s: %w", ErrFaultNotInjected, fault.Name, err))
+			}
+
+			// Fault can be injected partially
+			rnr.heal(ctx, fault, injected)
+
+			return
+		}
+
+		stopped := !sleep(ctx, hold)
+
+		if !rnr.heal(ctx, fault, injected) || stopped {
+			return
+		}
+	}
+}
+
+func (rnr *Runner) heal(ctx context.Context, fault Fault, injected Injected) bool {
+	if injected.Heal == nil {
+		return true
+	}
+
+	// Fault must be healed even if the schedule is stopped
+	err := injected.Heal(context.WithoutCancel(ctx))
+
+	rnr.record(ActionHeal, fault.Name, injected.Detail, err)
+
+	if err != nil {
+		rnr.fail(fmt.Errorf("%w: %s: %w", ErrFaultNotHealed, fault.Name, err))
+		return false
+	}
+
+	return true
+}
+
+func (rnr *Runner) record(action string, fault string, detail string, err error) {
+	event := Event{
+		Time:   time.Now(),
+		Action: action,
+		Fault:  fault,
+		Detail: detail,
+		Err:    err,
+	}
+
+	rnr.mutex.Lock()
+	rnr.events = append(rnr.events, event)
+	rnr.mutex.Unlock()
+
+	rnr.opts.Logf("chaos: %s", event)
+}
+
+func (rnr *Runner) fail(err error) {
+	rnr.mutex.Lock()
+	defer rnr.mutex.Unlock()
+
+	rnr.err = errors.Join(rnr.err, err)
+}
+
+func prepareSeed(seed uint64) (uint64, error) {
+	if seed != 0 {
+		return seed, nil
+	}
+
+	if value := os.Getenv(env.ChaosSeed); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrSeedInvalid, err)
+		}
+
+		return parsed, nil
+	}
+
+	return rand.Uint64(), nil //nolint:gosec // Cryptographic strength is not needed
+}
+
+func randomDuration(rnd *rand.Rand, minimum time.Duration, maximum time.Duration) time.Duration {
+	return minimum + time.Duration(rnd.Int64N(int64(maximum-minimum)+1))
+}
+
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
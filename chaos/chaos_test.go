@@ -0,0 +1,307 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/env"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errInjection = errors.New("injection failed")
+
+type counter struct {
+	injected atomic.Int64
+	healed   atomic.Int64
+}
+
+func (cnt *counter) fault(name string) Fault {
+	inject := func(_ context.Context, rnd *rand.Rand) (Injected, error) {
+		cnt.injected.Add(1)
+
+		injected := Injected{
+			Detail: nodeDetail(rnd.IntN(5)),
+			Heal: func(context.Context) error {
+				cnt.healed.Add(1)
+				return nil
+			},
+		}
+
+		return injected, nil
+	}
+
+	return Fault{Name: name, Inject: inject}
+}
+
+func testOpts(seed uint64) Opts {
+	opts := Opts{
+		Seed:     seed,
+		Duration: 200 * time.Millisecond,
+		MinHold:  time.Millisecond,
+		MaxHold:  3 * time.Millisecond,
+		MinRest:  time.Millisecond,
+		MaxRest:  3 * time.Millisecond,
+	}
+
+	return opts
+}
+
+func TestRunner(t *testing.T) {
+	cnt := &counter{}
+
+	rnr, err := Start(t.Context(), testOpts(1), cnt.fault("first"), cnt.fault("second"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), rnr.Seed())
+	require.NoError(t, rnr.Wait())
+
+	require.NotZero(t, cnt.injected.Load())
+	require.Equal(t, cnt.injected.Load(), cnt.healed.Load())
+
+	events := rnr.Events()
+
+	require.Len(t, events, int(2*cnt.injected.Load()))
+
+	for id := 0; id < len(events); id += 2 {
+		require.Equal(t, ActionInject, events[id].Action)
+		require.Equal(t, ActionHeal, events[id+1].Action)
+		require.Equal(t, events[id].Fault, events[id+1].Fault)
+		require.Equal(t, events[id].Detail, events[id+1].Detail)
+		require.False(t, events[id+1].Time.Before(events[id].Time))
+	}
+}
+
+func TestRunnerReproducible(t *testing.T) {
+	run := func(seed uint64) []Event {
+		cnt := &counter{}
+
+		rnr, err := Start(
+			t.Context(),
+			testOpts(seed),
+			cnt.fault("first"),
+			cnt.fault("second"),
+			cnt.fault("third"),
+		)
+		require.NoError(t, err)
+		require.NoError(t, rnr.Wait())
+
+		return rnr.Events()
+	}
+
+	first := run(42)
+	second := run(42)
+	other := run(43)
+
+	// Number of events depends on timings, but their sequence does not
+	length := min(len(first), len(second), len(other))
+
+	require.NotZero(t, length)
+
+	different := false
+
+	for id := range length {
+		require.Equal(t, first[id].Action, second[id].Action)
+		require.Equal(t, first[id].Fault, second[id].Fault)
+		require.Equal(t, first[id].Detail, second[id].Detail)
+
+		if first[id].Fault != other[id].Fault || first[id].Detail != other[id].Detail {
+			different = true
+		}
+	}
+
+	require.True(t, different)
+}
+
+func TestRunnerStop(t *testing.T) {
+	cnt := &counter{}
+
+	opts := testOpts(1)
+	opts.Duration = time.Hour
+	opts.MinHold = time.Hour
+	opts.MaxHold = time.Hour
+
+	var logged atomic.Int64
+
+	opts.Logf = func(string, ...any) {
+		logged.Add(1)
+	}
+
+	rnr, err := Start(t.Context(), opts, cnt.fault("first"))
+	require.NoError(t, err)
+
+	require.Eventually(
+		t,
+		func() bool { return cnt.injected.Load() == 1 },
+		time.Second,
+		time.Millisecond,
+	)
+
+	require.NoError(t, rnr.Stop())
+	require.Equal(t, int64(1), cnt.healed.Load())
+	require.Len(t, rnr.Events(), 2)
+	// Seed, injection and healing
+	require.Equal(t, int64(3), logged.Load())
+}
+
+func TestRunnerInjectionFailed(t *testing.T) {
+	var healed atomic.Bool
+
+	fault := Fault{
+		Name: "failed",
+		Inject: func(context.Context, *rand.Rand) (Injected, error) {
+			injected := Injected{
+				Heal: func(context.Context) error {
+					healed.Store(true)
+					return nil
+				},
+			}
+
+			return injected, errInjection
+		},
+	}
+
+	rnr, err := Start(t.Context(), testOpts(1), fault)
+	require.NoError(t, err)
+
+	err = rnr.Wait()
+	require.ErrorIs(t, err, ErrFaultNotInjected)
+	require.ErrorIs(t, err, errInjection)
+	require.True(t, healed.Load())
+
+	events := rnr.Events()
+
+	require.Len(t, events, 2)
+	require.ErrorIs(t, events[0].Err, errInjection)
+}
+
+func TestRunnerHealingFailed(t *testing.T) {
+	fault := Fault{
+		Name: "failed",
+		Inject: func(context.Context, *rand.Rand) (Injected, error) {
+			injected := Injected{
+				Heal: func(context.Context) error {
+					return errInjection
+				},
+			}
+
+			return injected, nil
+		},
+	}
+
+	rnr, err := Start(t.Context(), testOpts(1), fault)
+	require.NoError(t, err)
+
+	err = rnr.Wait()
+	require.ErrorIs(t, err, ErrFaultNotHealed)
+	require.ErrorIs(t, err, errInjection)
+	require.Len(t, rnr.Events(), 2)
+}
+
+func TestRunnerSeed(t *testing.T) {
+	cnt := &counter{}
+
+	t.Setenv(env.ChaosSeed, "12345")
+
+	rnr, err := Start(t.Context(), testOpts(0), cnt.fault("first"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(12345), rnr.Seed())
+	require.NoError(t, rnr.Stop())
+
+	t.Setenv(env.ChaosSeed, "seed")
+
+	_, err = Start(t.Context(), testOpts(0), cnt.fault("first"))
+	require.ErrorIs(t, err, ErrSeedInvalid)
+
+	t.Setenv(env.ChaosSeed, "")
+
+	rnr, err = Start(t.Context(), testOpts(0), cnt.fault("first"))
+	require.NoError(t, err)
+	require.NoError(t, rnr.Stop())
+}
+
+type fakeTB struct {
+	cleanups []func()
+	failed   bool
+	logs     []string
+}
+
+func (tb *fakeTB) Cleanup(fn func()) {
+	tb.cleanups = append(tb.cleanups, fn)
+}
+
+func (tb *fakeTB) Failed() bool {
+	return tb.failed
+}
+
+func (tb *fakeTB) Logf(format string, _ ...any) {
+	tb.logs = append(tb.logs, format)
+}
+
+func TestLogSeedOnFailure(t *testing.T) {
+	rnr := &Runner{seed: 7}
+
+	passed := &fakeTB{}
+
+	rnr.LogSeedOnFailure(passed)
+	passed.cleanups[0]()
+
+	require.Empty(t, passed.logs)
+
+	failed := &fakeTB{failed: true}
+
+	rnr.LogSeedOnFailure(failed)
+	failed.cleanups[0]()
+
+	require.Len(t, failed.logs, 1)
+}
+
+func TestStartWrongArgs(t *testing.T) {
+	cnt := &counter{}
+
+	_, err := Start(t.Context(), testOpts(1))
+	require.ErrorIs(t, err, ErrFaultsEmpty)
+
+	_, err = Start(t.Context(), testOpts(1), Fault{Name: "first"})
+	require.ErrorIs(t, err, ErrFaultInvalid)
+
+	_, err = Start(t.Context(), Opts{}, cnt.fault("first"))
+	require.ErrorIs(t, err, ErrDurationNotPositive)
+
+	opts := testOpts(1)
+	opts.MinHold = time.Second
+
+	_, err = Start(t.Context(), opts, cnt.fault("first"))
+	require.ErrorIs(t, err, ErrIntervalInvalid)
+
+	opts = testOpts(1)
+	opts.MinRest = -time.Second
+
+	_, err = Start(t.Context(), opts, cnt.fault("first"))
+	require.ErrorIs(t, err, ErrIntervalInvalid)
+}
+
+func TestEventString(t *testing.T) {
+	event := Event{
+		Time:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Action: ActionInject,
+		Fault:  "pause",
+		Detail: nodeDetail(1),
+		Err:    errInjection,
+	}
+
+	require.Equal(
+		t,
+		"2025-01-02T03:04:05Z inject pause: node 1: "+errInjection.Error(),
+		event.String(),
+	)
+
+	require.Equal(
+		t,
+		"2025-01-02T03:04:05Z heal pause",
+		Event{Time: event.Time, Action: ActionHeal, Fault: "pause"}.String(),
+	)
+}
@@ -0,0 +1,162 @@
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/akramarenkov/illusion/fault"
+	"github.com/akramarenkov/illusion/internal/netem"
+)
+
+// Target whose nodes can be paused, e.g. crdb.Cluster or psql.Group.
+type Pauser interface {
+	DSNs() []url.URL
+	Pause(ctx context.Context, id int) error
+	Unpause(ctx context.Context, id int) error
+}
+
+// Pauses a random node of the target.
+func Pause(target Pauser) Fault {
+	inject := func(ctx context.Context, rnd *rand.Rand) (Injected, error) {
+		id := rnd.IntN(len(target.DSNs()))
+
+		injected := Injected{
+			Detail: nodeDetail(id),
+			Heal: func(ctx context.Context) error {
+				return target.Unpause(ctx, id)
+			},
+		}
+
+		if err := target.Pause(ctx, id); err != nil {
+			return Injected{Detail: injected.Detail}, err
+		}
+
+		return injected, nil
+	}
+
+	fault := Fault{
+		Name:   "pause",
+		Inject: inject,
+	}
+
+	return fault
+}
+
+// Target whose nodes can be killed and started again, e.g. crdb.Cluster or
+// psql.Group.
+type Killer interface {
+	DSNs() []url.URL
+	Kill(ctx context.Context, id int) error
+	Start(ctx context.Context, id int) error
+}
+
+// Kills a random node of the target and starts it again on healing.
+//
+// Port of the node can change after the start, so the DSNs of the target must be
+// obtained again after the healing.
+func Kill(target Killer) Fault {
+	inject := func(ctx context.Context, rnd *rand.Rand) (Injected, error) {
+		id := rnd.IntN(len(target.DSNs()))
+
+		injected := Injected{
+			Detail: nodeDetail(id),
+			Heal: func(ctx context.Context) error {
+				return target.Start(ctx, id)
+			},
+		}
+
+		if err := target.Kill(ctx, id); err != nil {
+			return Injected{Detail: injected.Detail}, err
+		}
+
+		return injected, nil
+	}
+
+	fault := Fault{
+		Name:   "kill",
+		Inject: inject,
+	}
+
+	return fault
+}
+
+// Target whose network between nodes can be degraded, e.g. crdb.Cluster.
+type Degrader interface {
+	DSNs() []url.URL
+	SetNetem(ctx context.Context, from int, rules netem.Rules, peers ...int) error
+	RemoveNetem(ctx context.Context, from int, peers ...int) error
+}
+
+// Isolates a random node of the target from the other nodes by dropping all
+// packets sent by it to them.
+func Partition(target Degrader) Fault {
+	fault := degrade(target, "partition", netem.Rules{Loss: 100}) //nolint:mnd // All packets
+
+	return fault
+}
+
+// Delays packets sent by a random node of the target to the other nodes.
+func Latency(target Degrader, delay time.Duration, jitter time.Duration) Fault {
+	fault := degrade(target, "latency", netem.Rules{Delay: delay, Jitter: jitter})
+
+	return fault
+}
+
+func degrade(target Degrader, name string, rules netem.Rules) Fault {
+	inject := func(ctx context.Context, rnd *rand.Rand) (Injected, error) {
+		id := rnd.IntN(len(target.DSNs()))
+
+		injected := Injected{
+			Detail: nodeDetail(id),
+			Heal: func(ctx context.Context) error {
+				return target.RemoveNetem(ctx, id)
+			},
+		}
+
+		if err := target.SetNetem(ctx, id, rules); err != nil {
+			// Rules can be applied to a part of the peers
+			return injected, err
+		}
+
+		return injected, nil
+	}
+
+	fault := Fault{
+		Name:   name,
+		Inject: inject,
+	}
+
+	return fault
+}
+
+// Injects the specified faults into the proxy.
+func Proxy(proxy *fault.Proxy, faults fault.Faults) Fault {
+	inject := func(context.Context, *rand.Rand) (Injected, error) {
+		injected := Injected{
+			Detail: proxy.DSN().Host,
+			Heal: func(context.Context) error {
+				return proxy.Heal()
+			},
+		}
+
+		if err := proxy.Set(faults); err != nil {
+			return Injected{Detail: injected.Detail}, err
+		}
+
+		return injected, nil
+	}
+
+	fault := Fault{
+		Name:   "proxy",
+		Inject: inject,
+	}
+
+	return fault
+}
+
+func nodeDetail(id int) string {
+	return "node " + strconv.Itoa(id)
+}
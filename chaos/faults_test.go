@@ -0,0 +1,179 @@
+package chaos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/crdb"
+	"github.com/akramarenkov/illusion/fault"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/psql"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ Degrader = (*crdb.Cluster)(nil)
+	_ Killer   = (*crdb.Cluster)(nil)
+	_ Killer   = (*psql.Group)(nil)
+	_ Pauser   = (*crdb.Cluster)(nil)
+	_ Pauser   = (*psql.Group)(nil)
+)
+
+type testKiller struct {
+	dsns   []url.URL
+	killed []int
+	err    error
+}
+
+func (tkl *testKiller) DSNs() []url.URL {
+	return tkl.dsns
+}
+
+func (tkl *testKiller) Kill(_ context.Context, id int) error {
+	if tkl.err != nil {
+		return tkl.err
+	}
+
+	tkl.killed = append(tkl.killed, id)
+
+	return nil
+}
+
+func (tkl *testKiller) Start(_ context.Context, id int) error {
+	if index := slices.Index(tkl.killed, id); index >= 0 {
+		tkl.killed = slices.Delete(tkl.killed, index, index+1)
+	}
+
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	cleanup := interceptor.Prepare()
+	defer cleanup()
+
+	m.Run()
+}
+
+func TestCluster(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := crdb.StartCluster(t.Context(), "latest-v25.1", 3)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	dsn := clt.DSNs()[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE counter (id INT PRIMARY KEY, value INT)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO counter VALUES (1, 0)")
+	require.NoError(t, err)
+
+	opts := Opts{
+		Duration: 30 * time.Second,
+		Logf:     t.Logf,
+	}
+
+	rnr, err := Start(
+		t.Context(),
+		opts,
+		Pause(clt),
+		Partition(clt),
+		Latency(clt, 100*time.Millisecond, 10*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	rnr.LogSeedOnFailure(t)
+
+	increments := 0
+
+	for deadline := time.Now().Add(opts.Duration); time.Now().Before(deadline); {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+
+		if _, err := db.ExecContext(ctx, "UPDATE counter SET value = value + 1"); err == nil {
+			increments++
+		}
+
+		cancel()
+	}
+
+	require.NoError(t, rnr.Wait())
+	require.NotEmpty(t, rnr.Events())
+
+	// Faults are healed, so the cluster is available and no acknowledged increments
+	// are lost
+	var value int
+
+	require.NoError(
+		t,
+		db.QueryRowContext(t.Context(), "SELECT value FROM counter WHERE id = 1").Scan(&value),
+	)
+	require.GreaterOrEqual(t, value, increments)
+}
+
+func TestProxy(t *testing.T) {
+	upstream := url.URL{Scheme: "postgres", Host: "127.0.0.1:1"}
+
+	proxy, err := fault.Start(upstream)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, proxy.Close())
+	}()
+
+	faults := fault.Faults{Latency: time.Second}
+
+	injected, err := Proxy(proxy, faults).Inject(t.Context(), rand.New(rand.NewPCG(1, 1)))
+	require.NoError(t, err)
+	require.Equal(t, proxy.DSN().Host, injected.Detail)
+	require.Equal(t, faults, proxy.Faults())
+
+	require.NoError(t, injected.Heal(t.Context()))
+	require.Equal(t, fault.Faults{}, proxy.Faults())
+
+	_, err = Proxy(proxy, fault.Faults{Latency: -time.Second}).
+		Inject(t.Context(), rand.New(rand.NewPCG(1, 1)))
+	require.ErrorIs(t, err, fault.ErrLatencyNegative)
+}
+
+func TestKill(t *testing.T) {
+	target := &testKiller{dsns: make([]url.URL, 3)}
+
+	injected, err := Kill(target).Inject(t.Context(), rand.New(rand.NewPCG(1, 1)))
+	require.NoError(t, err)
+	require.Len(t, target.killed, 1)
+	require.Equal(t, nodeDetail(target.killed[0]), injected.Detail)
+
+	require.NoError(t, injected.Heal(t.Context()))
+	require.Empty(t, target.killed)
+
+	target.err = errors.New("kill")
+
+	injected, err = Kill(target).Inject(t.Context(), rand.New(rand.NewPCG(1, 1)))
+	require.ErrorIs(t, err, target.err)
+	require.Nil(t, injected.Heal)
+}
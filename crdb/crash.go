@@ -0,0 +1,78 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akramarenkov/illusion/internal/crash"
+)
+
+var (
+	ErrDataOnTmpfs    = errors.New("data of the node is placed on tmpfs and would be lost")
+	ErrNodeNotKilled  = errors.New("node was not killed")
+	ErrNodeNotStarted = errors.New("node was not started")
+)
+
+// Kills all processes of the node with the SIGKILL signal, which simulates a crash
+// of the node, e.g. due to a power failure. Connections to the node are reset.
+//
+// Network degradation rules applied to the packets sent from the node are removed,
+// since they do not survive the restart of the node.
+//
+// Node can not be killed if its data is placed on tmpfs (see [WithTmpfsSize]),
+// since the data is lost when the node is stopped.
+func (clt *Cluster) Kill(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, err)
+	}
+
+	if clt.opts.tmpfsSize != 0 {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, ErrDataOnTmpfs)
+	}
+
+	if err := clt.terminateSidecar(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, err)
+	}
+
+	if err := crash.Kill(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, err)
+	}
+
+	return nil
+}
+
+// Starts the node killed by [Cluster.Kill] and waits until it accepts connections.
+//
+// Port of the node can be published on another host port after the start, so the
+// DSNs of the cluster must be obtained again by the [Cluster.DSNs] method. Address
+// of the node in the cluster network can change too, so network degradation rules
+// applied to the packets sent to the node by other nodes are applied again.
+func (clt *Cluster) Start(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	if err := crash.Start(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	if err := node.req.WaitingFor.WaitUntilReady(ctx, node.container); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	dsn, err := node.dsn(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	clt.dsns[id] = dsn
+
+	if err := clt.reapplyNetem(ctx, id); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package crdb
+
+import (
+	"database/sql"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKill(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 3)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	open := func(dsn url.URL) *sql.DB {
+		dsn.Scheme = "postgres"
+
+		db, err := sql.Open("pgx", dsn.String())
+		require.NoError(t, err)
+
+		return db
+	}
+
+	db := open(clt.DSNs()[1])
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE survived (id INT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	require.NoError(t, clt.SetNetem(t.Context(), 0, Netem{Loss: 100}))
+	require.NoError(t, clt.SetNetem(t.Context(), 1, Netem{Delay: 200 * time.Millisecond}, 0))
+
+	require.NoError(t, clt.Kill(t.Context(), 0))
+
+	// Cluster of three nodes stays available without one of them
+	_, err = db.ExecContext(t.Context(), "INSERT INTO survived VALUES (1)")
+	require.NoError(t, err)
+
+	require.NoError(t, clt.Start(t.Context(), 0))
+
+	killed := open(clt.DSNs()[0])
+
+	defer func() {
+		require.NoError(t, killed.Close())
+	}()
+
+	// Rules of the killed node are removed
+	var id int
+
+	require.NoError(t, killed.QueryRowContext(t.Context(), "SELECT id FROM survived").Scan(&id))
+	require.Equal(t, 1, id)
+
+	// Rules of other nodes are applied to the address of the started node
+	require.GreaterOrEqual(t, ping(t, clt, 1, 0), 200*time.Millisecond)
+
+	require.ErrorIs(t, clt.Kill(t.Context(), 3), ErrNodeNotFound)
+	require.ErrorIs(t, clt.Start(t.Context(), -1), ErrNodeNotFound)
+}
+
+func TestKillTmpfs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 1, WithTmpfsSize(1<<30))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	require.ErrorIs(t, clt.Kill(t.Context(), 0), ErrDataOnTmpfs)
+}
@@ -7,8 +7,12 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/akramarenkov/illusion/internal/keep"
+	"github.com/akramarenkov/illusion/internal/logs"
 	"github.com/akramarenkov/illusion/internal/parallel"
 
 	"github.com/google/uuid"
@@ -25,6 +29,7 @@ var (
 	ErrClusterNotRemoved        = errors.New("cluster was not removed")
 	ErrNodesQuantityNegative    = errors.New("nodes quantity is negative")
 	ErrNodesQuantityZero        = errors.New("nodes quantity is zero")
+	ErrNodeNotFound             = errors.New("node with specified index was not found")
 )
 
 const (
@@ -33,9 +38,14 @@ const (
 	httpPortTCP   = "8080/tcp"
 	sqlPort       = "26257"
 	sqlPortTCP    = "26257/tcp"
+
+	defaultImage = "cockroachdb/cockroach"
 )
 
-type Cleanup func(ctx context.Context) error
+// Removes the cluster. If the ILLUSION_KEEP environment variable is set to
+// on-failure and any of the specified tests has failed, the cluster is kept running
+// for debugging instead (see [Cluster.Cleanup]).
+type Cleanup func(ctx context.Context, t ...TB) error
 
 type node struct {
 	container testcontainers.Container
@@ -54,77 +64,162 @@ func (n *node) Request() testcontainers.GenericContainerRequest {
 	return n.req
 }
 
-type cluster struct {
+// Running CockroachDB cluster. Nodes of the cluster are identified by their
+// indices, which correspond to the indices of the returned DSNs.
+type Cluster struct {
 	imageTag      string
 	nodesQuantity int
+	opts          options
+
+	dsns          []url.URL
+	keepOnFailure bool
+	network       *testcontainers.DockerNetwork
+	nodeLogs      *logs.Nodes
+	nodes         []*node
+
+	// Guards the sidecars and their rules, since rules of different nodes can be
+	// changed concurrently
+	netemMutex sync.Mutex
+	sidecars   map[int]*sidecar
+}
 
-	network *testcontainers.DockerNetwork
-	nodes   []*node
+// Runs CockroachDB cluster with the specified number of nodes.
+//
+// [Cleanup] function must be called when the cluster is no longer needed if
+// [RunCluster] did not return an error.
+func RunCluster(
+	ctx context.Context,
+	imageTag string,
+	nodesQuantity int,
+	opts ...Adjuster,
+) ([]url.URL, Cleanup, error) {
+	clt, err := StartCluster(ctx, imageTag, nodesQuantity, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return clt.DSNs(), clt.Cleanup, nil
 }
 
-func RunCluster(ctx context.Context, imageTag string, nodesQuantity int) ([]url.URL, Cleanup, error) {
+// Same as [RunCluster], but returns the cluster that provides operations on the
+// running nodes.
+//
+// [Cluster.Cleanup] method must be called when the cluster is no longer needed if
+// [StartCluster] did not return an error.
+func StartCluster(
+	ctx context.Context,
+	imageTag string,
+	nodesQuantity int,
+	opts ...Adjuster,
+) (*Cluster, error) {
 	if nodesQuantity < 0 {
-		return nil, nil, ErrNodesQuantityNegative
+		return nil, ErrNodesQuantityNegative
 	}
 
 	if nodesQuantity == 0 {
-		return nil, nil, ErrNodesQuantityZero
+		return nil, ErrNodesQuantityZero
 	}
 
-	clt := &cluster{
+	clt := &Cluster{
 		imageTag:      imageTag,
 		nodesQuantity: nodesQuantity,
 	}
 
-	dsns, err := clt.run(ctx)
-	if err != nil {
-		return nil, nil, errors.Join(err, clt.cleanup(ctx))
+	for _, adj := range opts {
+		if err := adj(&clt.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	clt.opts = clt.opts.normalize()
+	clt.keepOnFailure = keep.Enabled()
+	clt.nodeLogs = logs.NewNodes(clt.opts.logs)
+
+	if err := clt.run(ctx); err != nil {
+		// Context may be expired while waiting for the readiness of the nodes
+		err = clt.checkOOMKilled(context.WithoutCancel(ctx), err)
+		err = logs.AddTails(context.WithoutCancel(ctx), clt.nodeLogs, clt.nodes, err)
+
+		return nil, errors.Join(err, clt.Cleanup(ctx))
 	}
 
-	return dsns, clt.cleanup, nil
+	return clt, nil
 }
 
-func (clt *cluster) run(ctx context.Context) ([]url.URL, error) {
+// Returns DSNs of the nodes.
+func (clt *Cluster) DSNs() []url.URL {
+	return slices.Clone(clt.dsns)
+}
+
+func (clt *Cluster) run(ctx context.Context) error {
 	if err := clt.createNetwork(ctx); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := clt.runNodes(ctx); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := clt.initialize(ctx); err != nil {
-		return nil, err
+		return err
 	}
 
-	dsns := make([]url.URL, len(clt.nodes))
+	clt.dsns = make([]url.URL, len(clt.nodes))
 
 	for id, node := range clt.nodes {
-		host, err := node.container.Host(ctx)
+		dsn, err := node.dsn(ctx)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		port, err := node.container.MappedPort(ctx, sqlPortTCP)
-		if err != nil {
-			return nil, err
-		}
+		clt.dsns[id] = dsn
+	}
 
-		dsn := url.URL{
-			Scheme:   "cockroach",
-			User:     url.User("root"),
-			Host:     net.JoinHostPort(host, port.Port()),
-			Path:     "/",
-			RawQuery: url.Values{"sslmode": []string{"disable"}}.Encode(),
-		}
+	return nil
+}
 
-		dsns[id] = dsn
+func (n *node) dsn(ctx context.Context) (url.URL, error) {
+	host, err := n.container.Host(ctx)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	port, err := n.container.MappedPort(ctx, sqlPortTCP)
+	if err != nil {
+		return url.URL{}, err
 	}
 
-	return dsns, nil
+	dsn := url.URL{
+		Scheme:   "cockroach",
+		User:     url.User("root"),
+		Host:     net.JoinHostPort(host, port.Port()),
+		Path:     "/",
+		RawQuery: url.Values{"sslmode": []string{"disable"}}.Encode(),
+	}
+
+	return dsn, nil
 }
 
-func (clt *cluster) cleanup(ctx context.Context) error {
+// Terminates the nodes and removes the network of the cluster.
+//
+// If the ILLUSION_KEEP environment variable is set to on-failure and any of the
+// specified tests has failed, the cluster is kept running for debugging instead.
+// DSNs of the cluster, names of its resources and the command that removes them are
+// logged by the failed test. Kept clusters are not removed at the end of the
+// testcontainers session, but can be removed by the [RemoveKept] function.
+func (clt *Cluster) Cleanup(ctx context.Context, t ...TB) error {
+	if failed := clt.failedTest(t); failed != nil {
+		return clt.keep(ctx, failed)
+	}
+
+	return clt.cleanup(ctx)
+}
+
+func (clt *Cluster) cleanup(ctx context.Context) error {
+	if err := clt.terminateSidecars(); err != nil {
+		return fmt.Errorf("%w: %w", ErrClusterNotRemoved, err)
+	}
+
 	if err := parallel.Terminate(clt.nodes); err != nil {
 		return fmt.Errorf("%w: %w", ErrClusterNotRemoved, err)
 	}
@@ -137,11 +232,15 @@ func (clt *cluster) cleanup(ctx context.Context) error {
 		clt.network = nil
 	}
 
+	if err := clt.nodeLogs.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrClusterNotRemoved, err)
+	}
+
 	return nil
 }
 
-func (clt *cluster) createNetwork(ctx context.Context) error {
-	netwk, err := network.New(ctx)
+func (clt *Cluster) createNetwork(ctx context.Context) error {
+	netwk, err := network.New(ctx, clt.networkCustomizers()...)
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrClusterNetworkNotCreated, err)
 	}
@@ -151,7 +250,7 @@ func (clt *cluster) createNetwork(ctx context.Context) error {
 	return nil
 }
 
-func (clt *cluster) runNodes(ctx context.Context) error {
+func (clt *Cluster) runNodes(ctx context.Context) error {
 	if err := clt.prepareNodeRequests(); err != nil {
 		return fmt.Errorf(
 			"%w: preparing node requests: %w",
@@ -167,7 +266,7 @@ func (clt *cluster) runNodes(ctx context.Context) error {
 	return nil
 }
 
-func (clt *cluster) prepareNodeRequests() error {
+func (clt *Cluster) prepareNodeRequests() error {
 	hostnames, err := prepareHostnames(clt.nodesQuantity)
 	if err != nil {
 		return err
@@ -175,7 +274,7 @@ func (clt *cluster) prepareNodeRequests() error {
 
 	join := prepareJoin(hostnames)
 
-	clt.nodes = make([]*node, clt.nodesQuantity)
+	clt.nodes = make([]*node, 0, clt.nodesQuantity)
 
 	for id, hostname := range hostnames {
 		advertiseAddr := net.JoinHostPort(hostname, advertisePort)
@@ -186,7 +285,7 @@ func (clt *cluster) prepareNodeRequests() error {
 			ContainerRequest: testcontainers.ContainerRequest{
 				Name:     hostname,
 				Hostname: hostname,
-				Image:    "cockroachdb/cockroach:" + clt.imageTag,
+				Image:    clt.opts.image + ":" + clt.imageTag,
 				ExposedPorts: []string{
 					httpPort,
 					sqlPort,
@@ -196,9 +295,6 @@ func (clt *cluster) prepareNodeRequests() error {
 					wait.ForListeningPort(httpPortTCP),
 					wait.ForListeningPort(sqlPortTCP),
 				),
-				Mounts: testcontainers.Mounts(
-					testcontainers.VolumeMount("", "/cockroach/cockroach-data"),
-				),
 				Cmd: []string{
 					"start",
 					"--advertise-addr",
@@ -217,17 +313,27 @@ func (clt *cluster) prepareNodeRequests() error {
 			Started: true,
 		}
 
+		clt.prepareNodeStorage(&request)
+		clt.prepareNodeThrottle(id, &request)
+		clt.prepareNodeResources(id, &request)
+
+		if err := clt.nodeLogs.Prepare(id, hostname, &request); err != nil {
+			return err
+		}
+
+		clt.prepareKeep(&request)
+
 		prepared := &node{
 			req: request,
 		}
 
-		clt.nodes[id] = prepared
+		clt.nodes = append(clt.nodes, prepared)
 	}
 
 	return nil
 }
 
-func (clt *cluster) initialize(ctx context.Context) error {
+func (clt *Cluster) initialize(ctx context.Context) error {
 	node := clt.nodes[0]
 
 	info, err := node.container.Inspect(ctx)
@@ -306,6 +412,14 @@ func (clt *cluster) initialize(ctx context.Context) error {
 	}
 }
 
+func (clt *Cluster) node(id int) (*node, error) {
+	if id < 0 || id >= len(clt.nodes) {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+	}
+
+	return clt.nodes[id], nil
+}
+
 func prepareHostnames(quantity int) ([]string, error) {
 	hostnames := make([]string, quantity)
 
@@ -37,11 +37,11 @@ func TestRunCluster(t *testing.T) {
 	require.NoError(t, err)
 
 	defer func() {
-		require.NoError(t, cleanup(t.Context()))
+		require.NoError(t, cleanup(t.Context(), t))
 	}()
 
 	defer func() {
-		require.NoError(t, cleanup(t.Context()))
+		require.NoError(t, cleanup(t.Context(), t))
 	}()
 
 	for _, dsn := range dsns {
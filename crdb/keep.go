@@ -0,0 +1,96 @@
+package crdb
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/akramarenkov/illusion/internal/keep"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+)
+
+const (
+	keepKind = "crdb"
+)
+
+// Subset of the [testing.TB] interface.
+type TB interface {
+	Failed() bool
+	Logf(format string, args ...any)
+}
+
+// Removes all clusters kept on failure of the tests, e.g. after debugging or before
+// the next run of the tests.
+func RemoveKept(ctx context.Context) error {
+	return keep.Remove(ctx, keepKind)
+}
+
+// Returns the failed test for which the cluster should be kept.
+func (clt *Cluster) failedTest(tests []TB) TB {
+	if !clt.keepOnFailure {
+		return nil
+	}
+
+	for _, t := range tests {
+		if t != nil && t.Failed() {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (clt *Cluster) networkCustomizers() []network.NetworkCustomizer {
+	if !clt.keepOnFailure {
+		return nil
+	}
+
+	return []network.NetworkCustomizer{network.WithLabels(keep.NetworkLabels(keepKind))}
+}
+
+func (clt *Cluster) prepareKeep(req *testcontainers.GenericContainerRequest) {
+	if !clt.keepOnFailure {
+		return
+	}
+
+	keep.PrepareRequest(keepKind, req)
+}
+
+// Logs the description of the kept cluster and detaches the cluster from its
+// resources, so they are not removed by the subsequent calls of the cleanup.
+func (clt *Cluster) keep(ctx context.Context, t TB) error {
+	clt.netemMutex.Lock()
+	defer clt.netemMutex.Unlock()
+
+	containers := make([]testcontainers.Container, 0, len(clt.nodes)+len(clt.sidecars))
+
+	for _, node := range clt.nodes {
+		containers = append(containers, node.container)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(clt.sidecars)) {
+		containers = append(containers, clt.sidecars[id].container)
+	}
+
+	environ := keep.Environment{
+		DSNs:       clt.DSNs(),
+		Containers: keep.Names(ctx, containers...),
+	}
+
+	if clt.network != nil {
+		environ.Network = clt.network.Name
+	}
+
+	t.Logf("cockroach cluster: %s", environ)
+
+	clt.network = nil
+	clt.nodes = nil
+	clt.sidecars = nil
+
+	// Subsequent calls of the cleanup have nothing to keep or remove
+	clt.keepOnFailure = false
+
+	return clt.nodeLogs.Close()
+}
@@ -0,0 +1,99 @@
+package crdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/env"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/internal/keep"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+type testTB struct {
+	failed bool
+	logged []string
+}
+
+func (tb *testTB) Failed() bool {
+	return tb.failed
+}
+
+func (tb *testTB) Logf(format string, args ...any) {
+	tb.logged = append(tb.logged, fmt.Sprintf(format, args...))
+}
+
+func TestKeepOnFailure(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	t.Setenv(env.Keep, keep.OnFailure)
+
+	tb := &testTB{}
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 1)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, RemoveKept(context.WithoutCancel(t.Context())))
+	}()
+
+	dsn := clt.DSNs()[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	ping := func() error {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		return db.PingContext(ctx)
+	}
+
+	tb.failed = true
+
+	require.NoError(t, clt.Cleanup(t.Context(), tb))
+	require.NoError(t, clt.Cleanup(t.Context(), tb))
+	require.Len(t, tb.logged, 1)
+	require.Contains(t, tb.logged[0], clt.DSNs()[0].String())
+	require.Contains(t, tb.logged[0], "remove with: docker rm -f -v ")
+	require.NoError(t, ping())
+
+	require.NoError(t, RemoveKept(t.Context()))
+	require.Error(t, ping())
+}
+
+func TestKeepOnFailureFailedTest(t *testing.T) {
+	failed := &testTB{failed: true}
+	passed := &testTB{}
+
+	t.Setenv(env.Keep, "")
+
+	clt := &Cluster{keepOnFailure: keep.Enabled()}
+
+	require.Nil(t, clt.failedTest([]TB{failed}))
+	require.Nil(t, clt.networkCustomizers())
+
+	t.Setenv(env.Keep, keep.OnFailure)
+
+	clt = &Cluster{keepOnFailure: keep.Enabled()}
+
+	require.Nil(t, clt.failedTest(nil))
+	require.Nil(t, clt.failedTest([]TB{nil, passed}))
+	require.Same(t, failed, clt.failedTest([]TB{passed, failed}))
+	require.Len(t, clt.networkCustomizers(), 1)
+}
@@ -0,0 +1,43 @@
+package crdb
+
+import "github.com/akramarenkov/illusion/internal/logs"
+
+var (
+	ErrLogfNil            = logs.ErrLogfNil
+	ErrLogDirEmpty        = logs.ErrLogDirEmpty
+	ErrLogTailNotPositive = logs.ErrLogTailNotPositive
+)
+
+// Streams stdout and stderr of each node line by line to the logging function, e.g.
+// t.Logf. Lines are prefixed with the index and hostname of the node.
+//
+// Logging function must not be called after the end of the test, so the
+// [Cleanup] function must be called before it.
+func WithLogger(logf func(format string, args ...any)) Adjuster {
+	adj := func(opts *options) error {
+		return opts.logs.SetLogf(logf)
+	}
+
+	return adj
+}
+
+// Streams stdout and stderr of each node to the file named index-hostname.log in the
+// specified directory, which is created if it does not exist. Files are closed, but
+// not removed, by the [Cleanup] function.
+func WithLogFiles(dir string) Adjuster {
+	adj := func(opts *options) error {
+		return opts.logs.SetDir(dir)
+	}
+
+	return adj
+}
+
+// Includes the specified number of last lines of logs of each node in the error
+// returned when the cluster fails to start.
+func WithStartupLogTail(lines int) Adjuster {
+	adj := func(opts *options) error {
+		return opts.logs.SetTail(lines)
+	}
+
+	return adj
+}
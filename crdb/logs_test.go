@@ -0,0 +1,115 @@
+package crdb
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestLogs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	var (
+		mutex  sync.Mutex
+		logged []string
+	)
+
+	logf := func(format string, args ...any) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	dir := t.TempDir()
+
+	clt, err := StartCluster(
+		t.Context(),
+		"latest-v25.1",
+		2,
+		WithLogger(logf),
+		WithLogFiles(dir),
+	)
+	require.NoError(t, err)
+	require.NoError(t, clt.Cleanup(t.Context(), t))
+
+	for id, node := range clt.nodes {
+		prefix := "node " + strconv.Itoa(id) + " (" + node.req.Hostname + "): "
+		found := false
+
+		mutex.Lock()
+
+		for _, line := range logged {
+			if strings.HasPrefix(line, prefix) && strings.Contains(line, "CockroachDB node starting") {
+				found = true
+			}
+		}
+
+		mutex.Unlock()
+
+		require.True(t, found)
+
+		content, err := os.ReadFile(
+			filepath.Join(dir, strconv.Itoa(id)+"-"+node.req.Hostname+".log"),
+		)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "CockroachDB node starting")
+	}
+}
+
+func TestLogsStartupTail(t *testing.T) {
+	blocker := func(r *http.Request) bool {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		cmd := gjson.GetBytes(body, "Cmd").Array()
+
+		if len(cmd) < 2 {
+			return false
+		}
+
+		return cmd[0].String() == "cockroach" && cmd[1].String() == "init"
+	}
+
+	shutdown, err := interceptor.Run(blocker)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 3, WithStartupLogTail(5))
+	require.ErrorIs(t, err, ErrClusterNotInitialized)
+	require.Nil(t, clt)
+	require.ErrorContains(t, err, "last lines of logs of node 0")
+	require.ErrorContains(t, err, "last lines of logs of node 2")
+}
+
+func TestLogsOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithLogger(nil)(&opts), ErrLogfNil)
+	require.ErrorIs(t, WithLogFiles("")(&opts), ErrLogDirEmpty)
+	require.ErrorIs(t, WithStartupLogTail(-1)(&opts), ErrLogTailNotPositive)
+
+	require.NoError(t, WithLogger(t.Logf)(&opts))
+	require.NoError(t, WithLogFiles(t.TempDir())(&opts))
+	require.NoError(t, WithStartupLogTail(10)(&opts))
+	require.Equal(t, 10, opts.logs.Tail)
+}
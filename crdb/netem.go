@@ -0,0 +1,332 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/netem"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	DefaultNetemImage = "nicolaka/netshoot:v0.13"
+
+	// Rate of the classes that does not limit the traffic
+	netemClassRate = "100gbit"
+	// Class of the traffic to the peers without rules
+	netemDefaultClass = 1
+	// Classes of the traffic to the peers are offset by the index of the peer
+	netemPeerClassOffset = 10
+)
+
+var (
+	ErrImageEmpty        = errors.New("image is empty")
+	ErrNetemNotApplied   = errors.New("network degradation rules were not applied")
+	ErrNetemDelay        = netem.ErrDelayNegative
+	ErrNetemPercent      = netem.ErrPercentOutOfRange
+	ErrNetemReorderDelay = netem.ErrReorderWithoutDelay
+	ErrPeerIsSelf        = errors.New("node cannot be a peer of itself")
+	ErrDeviceNotFound    = errors.New("network device of the node was not found")
+	ErrAddressNotFound   = errors.New("address of the node was not found")
+)
+
+// Network degradation rules applied by tc netem to the packets sent from one node
+// to another. Percentages are specified in the range [0, 100].
+type Netem = netem.Rules
+
+// Container that shares the network namespace of the node and has the NET_ADMIN
+// capability, so the node itself runs without additional privileges and utilities.
+type sidecar struct {
+	container testcontainers.Container
+	device    string
+	// Rules keyed by index of the peer
+	rules map[int]Netem
+}
+
+// Applies network degradation rules to the packets sent from the node with the
+// specified index to the specified peers. If peers are not specified, rules are
+// applied to all other nodes of the cluster. Rules replace the previously applied
+// ones for the same peers.
+//
+// Rules affect only one direction, so for symmetric degradation they must be
+// applied on both nodes. Packets sent to the clients are not affected.
+func (clt *Cluster) SetNetem(ctx context.Context, from int, rules Netem, peers ...int) error {
+	if err := netem.Validate(rules); err != nil {
+		return err
+	}
+
+	return clt.changeNetem(ctx, from, peers, func(side *sidecar, peer int) {
+		side.rules[peer] = rules
+	})
+}
+
+// Removes network degradation rules applied to the packets sent from the node with
+// the specified index to the specified peers. If peers are not specified, all rules
+// of the node are removed.
+func (clt *Cluster) RemoveNetem(ctx context.Context, from int, peers ...int) error {
+	return clt.changeNetem(ctx, from, peers, func(side *sidecar, peer int) {
+		delete(side.rules, peer)
+	})
+}
+
+func (clt *Cluster) changeNetem(
+	ctx context.Context,
+	from int,
+	peers []int,
+	change func(side *sidecar, peer int),
+) error {
+	if _, err := clt.node(from); err != nil {
+		return err
+	}
+
+	if len(peers) == 0 {
+		for id := range clt.nodes {
+			if id != from {
+				peers = append(peers, id)
+			}
+		}
+	}
+
+	// Cluster of a single node
+	if len(peers) == 0 {
+		return nil
+	}
+
+	for _, peer := range peers {
+		if _, err := clt.node(peer); err != nil {
+			return err
+		}
+
+		if peer == from {
+			return fmt.Errorf("%w: %d", ErrPeerIsSelf, peer)
+		}
+	}
+
+	clt.netemMutex.Lock()
+	defer clt.netemMutex.Unlock()
+
+	side, err := clt.sidecar(ctx, from)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNetemNotApplied, err)
+	}
+
+	for _, peer := range peers {
+		change(side, peer)
+	}
+
+	if err := clt.applyNetem(ctx, side); err != nil {
+		return fmt.Errorf("%w: %w", ErrNetemNotApplied, err)
+	}
+
+	return nil
+}
+
+// Rules are rebuilt from scratch on every change: the root htb qdisc directs the
+// traffic to each peer with rules into a separate class with the netem qdisc.
+func (clt *Cluster) applyNetem(ctx context.Context, side *sidecar) error {
+	// Qdisc may not exist, e.g. when rules are applied for the first time
+	_, _ = execute.Run(
+		ctx,
+		side.container,
+		[]string{"tc", "qdisc", "del", "dev", side.device, "root"},
+	)
+
+	if len(side.rules) == 0 {
+		return nil
+	}
+
+	commands := [][]string{
+		{
+			"tc", "qdisc", "add", "dev", side.device,
+			"root", "handle", "1:", "htb", "default", strconv.Itoa(netemDefaultClass),
+		},
+		{
+			"tc", "class", "add", "dev", side.device,
+			"parent", "1:", "classid", "1:" + strconv.Itoa(netemDefaultClass),
+			"htb", "rate", netemClassRate,
+		},
+	}
+
+	for _, peer := range slices.Sorted(maps.Keys(side.rules)) {
+		address, err := clt.address(ctx, peer)
+		if err != nil {
+			return err
+		}
+
+		class := strconv.Itoa(peer + netemPeerClassOffset)
+
+		commands = append(
+			commands,
+			[]string{
+				"tc", "class", "add", "dev", side.device,
+				"parent", "1:", "classid", "1:" + class,
+				"htb", "rate", netemClassRate,
+			},
+			slices.Concat(
+				[]string{
+					"tc", "qdisc", "add", "dev", side.device,
+					"parent", "1:" + class, "handle", class + ":", "netem",
+				},
+				netem.Args(side.rules[peer]),
+			),
+			[]string{
+				"tc", "filter", "add", "dev", side.device,
+				"protocol", "ip", "parent", "1:", "prio", "1",
+				"u32", "match", "ip", "dst", address + "/32", "flowid", "1:" + class,
+			},
+		)
+	}
+
+	for _, cmd := range commands {
+		if _, err := execute.Run(ctx, side.container, cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Filters of the rules match the address of the peer, which can change after the
+// restart of the peer.
+func (clt *Cluster) reapplyNetem(ctx context.Context, peer int) error {
+	clt.netemMutex.Lock()
+	defer clt.netemMutex.Unlock()
+
+	for _, side := range clt.sidecars {
+		if _, exists := side.rules[peer]; !exists {
+			continue
+		}
+
+		if err := clt.applyNetem(ctx, side); err != nil {
+			return fmt.Errorf("%w: %w", ErrNetemNotApplied, err)
+		}
+	}
+
+	return nil
+}
+
+func (clt *Cluster) sidecar(ctx context.Context, id int) (*sidecar, error) {
+	if side, exists := clt.sidecars[id]; exists {
+		return side, nil
+	}
+
+	node := clt.nodes[id]
+
+	request := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:      clt.opts.netemImage,
+			Entrypoint: []string{"sleep", "infinity"},
+			HostConfigModifier: func(config *container.HostConfig) {
+				config.NetworkMode = container.NetworkMode("container:" + node.container.GetContainerID())
+				config.CapAdd = append(config.CapAdd, "NET_ADMIN")
+			},
+		},
+		Started: true,
+	}
+
+	clt.prepareKeep(&request)
+
+	created, err := testcontainers.GenericContainer(ctx, request)
+	if err != nil {
+		return nil, errors.Join(err, testcontainers.TerminateContainer(created))
+	}
+
+	device, err := clt.device(ctx, id, created)
+	if err != nil {
+		return nil, errors.Join(err, testcontainers.TerminateContainer(created))
+	}
+
+	side := &sidecar{
+		container: created,
+		device:    device,
+		rules:     make(map[int]Netem),
+	}
+
+	if clt.sidecars == nil {
+		clt.sidecars = make(map[int]*sidecar)
+	}
+
+	clt.sidecars[id] = side
+
+	return side, nil
+}
+
+// Node is connected only to the cluster network, but the device is determined by
+// the route to the peer to be sure.
+func (clt *Cluster) device(ctx context.Context, id int, side execute.Executor) (string, error) {
+	peer := (id + 1) % len(clt.nodes)
+
+	address, err := clt.address(ctx, peer)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := execute.Run(ctx, side, []string{"ip", "-o", "route", "get", address})
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(string(output))
+
+	if position := slices.Index(fields, "dev"); position >= 0 && position+1 < len(fields) {
+		return fields[position+1], nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrDeviceNotFound, output)
+}
+
+func (clt *Cluster) terminateSidecars() error {
+	clt.netemMutex.Lock()
+	defer clt.netemMutex.Unlock()
+
+	for id, side := range clt.sidecars {
+		if err := testcontainers.TerminateContainer(side.container); err != nil {
+			return err
+		}
+
+		delete(clt.sidecars, id)
+	}
+
+	return nil
+}
+
+func (clt *Cluster) terminateSidecar(id int) error {
+	clt.netemMutex.Lock()
+	defer clt.netemMutex.Unlock()
+
+	side, exists := clt.sidecars[id]
+	if !exists {
+		return nil
+	}
+
+	if err := testcontainers.TerminateContainer(side.container); err != nil {
+		return err
+	}
+
+	delete(clt.sidecars, id)
+
+	return nil
+}
+
+// Returns address of the node in the cluster network.
+func (clt *Cluster) address(ctx context.Context, id int) (string, error) {
+	info, err := clt.nodes[id].container.Inspect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	settings, exists := info.NetworkSettings.Networks[clt.network.Name]
+	if !exists || settings.IPAddress == "" {
+		return "", fmt.Errorf("%w: %d", ErrAddressNotFound, id)
+	}
+
+	return settings.IPAddress, nil
+}
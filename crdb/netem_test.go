@@ -0,0 +1,85 @@
+package crdb
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetem(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 3)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	require.NoError(t, clt.SetNetem(t.Context(), 0, Netem{Delay: 200 * time.Millisecond}, 1))
+
+	require.GreaterOrEqual(t, ping(t, clt, 0, 1), 200*time.Millisecond)
+	require.Less(t, ping(t, clt, 0, 2), 200*time.Millisecond)
+
+	require.NoError(t, clt.SetNetem(t.Context(), 0, Netem{Delay: 100 * time.Millisecond}))
+
+	require.GreaterOrEqual(t, ping(t, clt, 0, 1), 100*time.Millisecond)
+	require.Less(t, ping(t, clt, 0, 1), 200*time.Millisecond)
+	require.GreaterOrEqual(t, ping(t, clt, 0, 2), 100*time.Millisecond)
+
+	require.NoError(t, clt.RemoveNetem(t.Context(), 0, 2))
+
+	require.GreaterOrEqual(t, ping(t, clt, 0, 1), 100*time.Millisecond)
+	require.Less(t, ping(t, clt, 0, 2), 100*time.Millisecond)
+
+	require.NoError(t, clt.RemoveNetem(t.Context(), 0))
+
+	require.Less(t, ping(t, clt, 0, 1), 100*time.Millisecond)
+
+	require.ErrorIs(t, clt.SetNetem(t.Context(), 0, Netem{}, 0), ErrPeerIsSelf)
+	require.ErrorIs(t, clt.SetNetem(t.Context(), 0, Netem{}, 3), ErrNodeNotFound)
+	require.ErrorIs(t, clt.RemoveNetem(t.Context(), -1), ErrNodeNotFound)
+}
+
+func ping(t *testing.T, clt *Cluster, from int, to int) time.Duration {
+	address, err := clt.address(t.Context(), to)
+	require.NoError(t, err)
+
+	output, err := execute.Run(
+		t.Context(),
+		clt.sidecars[from].container,
+		[]string{"ping", "-c", "1", "-q", address},
+	)
+	require.NoError(t, err)
+
+	// rtt min/avg/max/mdev = 200.123/200.123/200.123/0.000 ms
+	matched := regexp.MustCompile(`= [\d.]+/([\d.]+)/`).FindSubmatch(output)
+	require.Len(t, matched, 2)
+
+	milliseconds, err := strconv.ParseFloat(string(matched[1]), 64)
+	require.NoError(t, err)
+
+	return time.Duration(milliseconds * float64(time.Millisecond))
+}
+
+func TestNetemWrongArgs(t *testing.T) {
+	require.ErrorIs(t, new(Cluster).SetNetem(t.Context(), 0, Netem{Delay: -time.Second}), ErrNetemDelay)
+
+	opts := options{}
+
+	require.ErrorIs(t, WithNetemImage("")(&opts), ErrImageEmpty)
+	require.NoError(t, WithNetemImage("netshoot")(&opts))
+	require.Equal(t, "netshoot", opts.normalize().netemImage)
+	require.Equal(t, defaultImage, opts.normalize().image)
+}
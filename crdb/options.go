@@ -0,0 +1,48 @@
+package crdb
+
+import (
+	"github.com/akramarenkov/illusion/internal/logs"
+	"github.com/akramarenkov/illusion/internal/storage"
+)
+
+// Provides adjusting of a CockroachDB cluster.
+type Adjuster func(opts *options) error
+
+type options struct {
+	image         string
+	ioThrottles   storage.Throttles
+	logs          logs.Opts
+	netemImage    string
+	nodeResources map[int]Resources
+	resources     *Resources
+	tmpfsSize     int64
+}
+
+func (opts options) normalize() options {
+	if opts.image == "" {
+		opts.image = defaultImage
+	}
+
+	if opts.netemImage == "" {
+		opts.netemImage = DefaultNetemImage
+	}
+
+	return opts
+}
+
+// Sets image of the sidecar containers used to apply network degradation rules
+// (see [Cluster.SetNetem]). Image must contain the tc and ip utilities. By default
+// [DefaultNetemImage] is used.
+func WithNetemImage(image string) Adjuster {
+	adj := func(opts *options) error {
+		if image == "" {
+			return ErrImageEmpty
+		}
+
+		opts.netemImage = image
+
+		return nil
+	}
+
+	return adj
+}
@@ -0,0 +1,65 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/pause"
+)
+
+var (
+	ErrNodeNotPaused   = errors.New("node was not paused")
+	ErrNodeNotUnpaused = errors.New("node was not unpaused")
+)
+
+// Pauses all processes of the node, which simulates a stalled process, e.g. a long
+// garbage collection pause. Connections to the node are not closed.
+func (clt *Cluster) Pause(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotPaused, err)
+	}
+
+	if err := pause.Pause(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotPaused, err)
+	}
+
+	return nil
+}
+
+// Unpauses all processes of the node paused by [Cluster.Pause].
+func (clt *Cluster) Unpause(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotUnpaused, err)
+	}
+
+	if err := pause.Unpause(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotUnpaused, err)
+	}
+
+	return nil
+}
+
+// Pauses the node and unpauses it in the background after the specified duration or
+// earlier if the context is canceled. Result of the unpausing is sent to the
+// returned channel, after which the channel is closed.
+func (clt *Cluster) PauseFor(
+	ctx context.Context,
+	id int,
+	duration time.Duration,
+) (<-chan error, error) {
+	node, err := clt.node(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNodeNotPaused, err)
+	}
+
+	resumed, err := pause.For(ctx, node.container.GetContainerID(), duration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNodeNotPaused, err)
+	}
+
+	return resumed, nil
+}
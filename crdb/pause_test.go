@@ -0,0 +1,67 @@
+package crdb
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPause(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 3)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	dsn := clt.DSNs()[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	ping := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(t.Context(), timeout)
+		defer cancel()
+
+		return db.PingContext(ctx)
+	}
+
+	require.NoError(t, ping(time.Second))
+
+	require.NoError(t, clt.Pause(t.Context(), 0))
+	require.Error(t, ping(time.Second))
+	require.NoError(t, clt.Unpause(t.Context(), 0))
+	require.NoError(t, ping(10*time.Second))
+
+	started := time.Now()
+
+	resumed, err := clt.PauseFor(t.Context(), 0, 3*time.Second)
+	require.NoError(t, err)
+	require.NoError(t, ping(10*time.Second))
+	require.GreaterOrEqual(t, time.Since(started), 3*time.Second)
+	require.NoError(t, <-resumed)
+
+	require.ErrorIs(t, clt.Pause(t.Context(), 3), ErrNodeNotFound)
+	require.ErrorIs(t, clt.Unpause(t.Context(), -1), ErrNodeNotFound)
+
+	_, err = clt.PauseFor(t.Context(), 0, 0)
+	require.ErrorIs(t, err, ErrNodeNotPaused)
+}
@@ -0,0 +1,130 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	res := Resources{
+		CPUs:      1,
+		Memory:    1 << 30,
+		PidsLimit: 500,
+	}
+
+	clt, err := StartCluster(
+		t.Context(),
+		"latest-v25.1",
+		2,
+		WithResources(res),
+		WithResources(Resources{CPUSet: "0"}, 1),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	first, err := clt.nodes[0].container.Inspect(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, int64(1_000_000_000), first.HostConfig.NanoCPUs)
+	require.Equal(t, int64(1<<30), first.HostConfig.Memory)
+	require.Equal(t, int64(1<<30), first.HostConfig.MemorySwap)
+	require.Equal(t, int64(500), *first.HostConfig.PidsLimit)
+
+	second, err := clt.nodes[1].container.Inspect(t.Context())
+	require.NoError(t, err)
+
+	require.Zero(t, second.HostConfig.Memory)
+	require.Equal(t, "0", second.HostConfig.CpusetCpus)
+
+	require.NoError(t, clt.CheckOOMKilled(t.Context()))
+
+	nanoCPUs := func(id int) int64 {
+		info, err := clt.nodes[id].container.Inspect(t.Context())
+		require.NoError(t, err)
+
+		return info.HostConfig.NanoCPUs
+	}
+
+	require.NoError(t, clt.SetCPUs(t.Context(), 0, 0.1))
+	require.Equal(t, int64(100_000_000), nanoCPUs(0))
+	require.NoError(t, clt.RestoreCPUs(t.Context(), 0))
+	require.Equal(t, int64(1_000_000_000), nanoCPUs(0))
+
+	require.NoError(t, clt.SetCPUs(t.Context(), 1, 0.2))
+	require.Equal(t, int64(200_000_000), nanoCPUs(1))
+	require.NoError(t, clt.RestoreCPUs(t.Context(), 1))
+	require.Greater(t, nanoCPUs(1), int64(200_000_000))
+
+	require.ErrorIs(t, clt.SetCPUs(t.Context(), 0, 0), ErrCPUsNotPositive)
+	require.ErrorIs(t, clt.SetCPUs(t.Context(), 2, 1), ErrNodeNotFound)
+	require.ErrorIs(t, clt.RestoreCPUs(t.Context(), -1), ErrNodeNotFound)
+}
+
+func TestResourcesOOMKilled(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+
+	clt, err := StartCluster(
+		ctx,
+		"latest-v25.1",
+		1,
+		WithResources(Resources{Memory: 32 << 20}),
+	)
+	require.ErrorIs(t, err, ErrNodeOOMKilled)
+	require.Nil(t, clt)
+
+	var oomErr *OOMKilledError
+
+	require.ErrorAs(t, err, &oomErr)
+	require.Equal(t, 0, oomErr.Node)
+}
+
+func TestResourcesOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithResources(Resources{CPUs: -1})(&opts), ErrCPUsNegative)
+	require.ErrorIs(t, WithResources(Resources{Memory: -1})(&opts), ErrMemoryNegative)
+	require.ErrorIs(t, WithResources(Resources{Swap: 1})(&opts), ErrSwapWithoutMemory)
+	require.ErrorIs(t, WithResources(Resources{PidsLimit: -1})(&opts), ErrPidsLimitNegative)
+	require.ErrorIs(t, WithResources(Resources{}, -1)(&opts), ErrNodeNotFound)
+
+	require.Nil(t, opts.resourcesOfNode(0))
+
+	require.NoError(t, WithResources(Resources{CPUSet: "0", Memory: 1 << 20, Swap: -1})(&opts))
+	require.NoError(t, WithResources(Resources{CPUs: 2}, 1)(&opts))
+
+	require.Equal(t, &Resources{CPUSet: "0", Memory: 1 << 20, Swap: -1}, opts.resourcesOfNode(0))
+	require.Equal(t, &Resources{CPUs: 2}, opts.resourcesOfNode(1))
+}
+
+func TestCheckOOMKilled(t *testing.T) {
+	cause := errors.New("readiness timeout")
+
+	clt := &Cluster{nodes: []*node{{}}}
+
+	require.NoError(t, clt.CheckOOMKilled(t.Context()))
+	require.ErrorIs(t, clt.checkOOMKilled(t.Context(), cause), cause)
+}
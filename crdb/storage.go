@@ -0,0 +1,124 @@
+package crdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/hostconfig"
+	"github.com/akramarenkov/illusion/internal/storage"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+var (
+	ErrTmpfsSizeNotPositive = errors.New("tmpfs size is zero or negative")
+	ErrThrottleDeviceEmpty  = storage.ErrThrottleDeviceEmpty
+	ErrDataDirNotLimited    = errors.New("data directory is not placed on size-limited tmpfs")
+	ErrDataDirNotFilled     = errors.New("data directory was not filled")
+	ErrDataDirNotFreed      = errors.New("data directory was not freed")
+)
+
+const dataDir = "/cockroach/cockroach-data"
+
+// Limits of block I/O of a node. Zero value of a limit means no limit.
+//
+// Limits are applied by the kernel to the specified block device of the host, so
+// the device must be the one on which the data of the node is placed, e.g. the
+// device that holds the data root of the Docker daemon. Write limits affect only
+// direct I/O and writeback accounted by the cgroup of the container.
+type IOThrottle = storage.IOThrottle
+
+// Places the data directories of the nodes on tmpfs limited to the specified size in
+// bytes instead of volumes. Allows to drive a node into running out of disk space,
+// e.g. with the [Cluster.FillDataDir] method.
+//
+// Size must be enough to start the node. Part of the space, 1% but no more than
+// 1 GiB, is reserved by the node for the emergency ballast file.
+func WithTmpfsSize(size int64) Adjuster {
+	adj := func(opts *options) error {
+		if size <= 0 {
+			return ErrTmpfsSizeNotPositive
+		}
+
+		opts.tmpfsSize = size
+
+		return nil
+	}
+
+	return adj
+}
+
+// Limits block I/O of the nodes with the specified indices. If indices are not
+// specified, limits are used for all nodes for which they are not specified
+// individually.
+func WithIOThrottle(throttle IOThrottle, nodes ...int) Adjuster {
+	adj := func(opts *options) error {
+		return opts.ioThrottles.Set(throttle, nodes, ErrNodeNotFound)
+	}
+
+	return adj
+}
+
+func (clt *Cluster) prepareNodeStorage(req *testcontainers.GenericContainerRequest) {
+	if clt.opts.tmpfsSize == 0 {
+		req.Mounts = testcontainers.Mounts(
+			testcontainers.VolumeMount("", dataDir),
+		)
+
+		return
+	}
+
+	req.Tmpfs = map[string]string{
+		dataDir: "rw,size=" + strconv.FormatInt(clt.opts.tmpfsSize, 10),
+	}
+}
+
+func (clt *Cluster) prepareNodeThrottle(id int, req *testcontainers.GenericContainerRequest) {
+	throttle := clt.opts.ioThrottles.Node(id)
+	if throttle == nil {
+		return
+	}
+
+	hostconfig.Add(req, func(config *container.HostConfig) {
+		storage.Apply(*throttle, config)
+	})
+}
+
+// Fills the data directory of the node, placed on size-limited tmpfs (see
+// [WithTmpfsSize]), with a filler file until there is no space left on it. Space is
+// released by the [Cluster.FreeDataDir] method.
+func (clt *Cluster) FillDataDir(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFilled, err)
+	}
+
+	if clt.opts.tmpfsSize == 0 {
+		return ErrDataDirNotLimited
+	}
+
+	if _, err := execute.Run(ctx, node.container, storage.FillCommand(dataDir)); err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFilled, err)
+	}
+
+	return nil
+}
+
+// Removes the filler file created by the [Cluster.FillDataDir] method from the data
+// directory of the node.
+func (clt *Cluster) FreeDataDir(ctx context.Context, id int) error {
+	node, err := clt.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFreed, err)
+	}
+
+	if _, err := execute.Run(ctx, node.container, storage.FreeCommand(dataDir)); err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFreed, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package crdb
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestFillDataDir(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	clt, err := StartCluster(t.Context(), "latest-v25.1", 1, WithTmpfsSize(1<<30))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, clt.Cleanup(t.Context(), t))
+	}()
+
+	dsn := clt.DSNs()[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE filled (payload STRING)")
+	require.NoError(t, err)
+
+	require.NoError(t, clt.FillDataDir(t.Context(), 0))
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+
+	// Node stops serving writes when disk space runs out
+	_, err = db.ExecContext(
+		ctx,
+		"INSERT INTO filled SELECT repeat('x', 1024) FROM generate_series(1, 100000)",
+	)
+	require.Error(t, err)
+
+	require.ErrorIs(t, clt.FillDataDir(t.Context(), 1), ErrNodeNotFound)
+	require.ErrorIs(t, clt.FreeDataDir(t.Context(), 1), ErrNodeNotFound)
+}
+
+func TestFillDataDirNotLimited(t *testing.T) {
+	clt := &Cluster{nodes: []*node{{}}}
+
+	require.ErrorIs(t, clt.FillDataDir(t.Context(), 0), ErrDataDirNotLimited)
+}
+
+func TestStorageOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithTmpfsSize(-1)(&opts), ErrTmpfsSizeNotPositive)
+	require.ErrorIs(t, WithIOThrottle(IOThrottle{})(&opts), ErrThrottleDeviceEmpty)
+	require.ErrorIs(
+		t,
+		WithIOThrottle(IOThrottle{Device: "/dev/sda"}, -1)(&opts),
+		ErrNodeNotFound,
+	)
+
+	require.NoError(t, WithTmpfsSize(1<<20)(&opts))
+	require.NoError(t, WithIOThrottle(IOThrottle{Device: "/dev/sda", WriteBps: 1})(&opts))
+	require.NoError(t, WithIOThrottle(IOThrottle{Device: "/dev/sdb", ReadIOps: 2}, 1)(&opts))
+
+	clt := &Cluster{opts: opts}
+
+	var first, second testcontainers.GenericContainerRequest
+
+	clt.prepareNodeStorage(&first)
+	clt.prepareNodeThrottle(0, &first)
+	clt.prepareNodeThrottle(1, &second)
+
+	require.Equal(t, map[string]string{dataDir: "rw,size=1048576"}, first.Tmpfs)
+	require.Empty(t, first.Mounts)
+
+	var config container.HostConfig
+
+	first.HostConfigModifier(&config)
+	second.HostConfigModifier(&config)
+
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sda", Rate: 1}},
+		config.BlkioDeviceWriteBps,
+	)
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sdb", Rate: 2}},
+		config.BlkioDeviceReadIOps,
+	)
+	require.Empty(t, config.BlkioDeviceReadBps)
+	require.Empty(t, config.BlkioDeviceWriteIOps)
+
+	var volume testcontainers.GenericContainerRequest
+
+	(&Cluster{}).prepareNodeStorage(&volume)
+
+	require.Empty(t, volume.Tmpfs)
+	require.Len(t, volume.Mounts, 1)
+}
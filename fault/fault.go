@@ -0,0 +1,381 @@
+// Injects network faults between clients and databases by an in-process TCP proxy.
+package fault
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/rewrite"
+)
+
+const (
+	listenAddress = "127.0.0.1:0"
+
+	defaultChunkSize = 32 * 1024
+	// Number of chunks transferred per second when bandwidth is limited
+	bandwidthChunksPerSecond = 10
+)
+
+var (
+	ErrLatencyNegative    = errors.New("latency is negative")
+	ErrJitterNegative     = errors.New("jitter is negative")
+	ErrBandwidthNegative  = errors.New("bandwidth is negative")
+	ErrResetAfterNegative = errors.New("number of bytes before reset is negative")
+	ErrProxyClosed        = errors.New("proxy is closed")
+)
+
+// Faults injected by the proxy. Zero value means no faults.
+type Faults struct {
+	// Delay of each chunk of data transferred in either direction
+	Latency time.Duration
+	// Random deviation of the latency in the range [-Jitter, +Jitter]
+	Jitter time.Duration
+	// Limit of the transfer rate in bytes per second in each direction of each
+	// connection. Zero means no limit
+	Bandwidth int
+	// Connections are reset after the specified number of bytes is transferred
+	// through them in both directions. Zero means no resets
+	ResetAfter int
+	// Data is accepted from both sides but is not forwarded, so connections look
+	// established, but hang (half-open)
+	Blackhole bool
+	// New connections are reset right after they are accepted, established ones
+	// are not affected
+	Refuse bool
+}
+
+func (faults Faults) validate() error {
+	if faults.Latency < 0 {
+		return ErrLatencyNegative
+	}
+
+	if faults.Jitter < 0 {
+		return ErrJitterNegative
+	}
+
+	if faults.Bandwidth < 0 {
+		return ErrBandwidthNegative
+	}
+
+	if faults.ResetAfter < 0 {
+		return ErrResetAfterNegative
+	}
+
+	return nil
+}
+
+func (faults Faults) delay() time.Duration {
+	delay := faults.Latency
+
+	if faults.Jitter > 0 {
+		//nolint:gosec // Cryptographic strength is not needed
+		deviation := rand.Int64N(2*int64(faults.Jitter) + 1)
+		delay += time.Duration(deviation) - faults.Jitter
+	}
+
+	return max(delay, 0)
+}
+
+func (faults Faults) chunkSize() int {
+	if faults.Bandwidth == 0 {
+		return defaultChunkSize
+	}
+
+	return max(min(faults.Bandwidth/bandwidthChunksPerSecond, defaultChunkSize), 1)
+}
+
+// Proxy that injects faults into the connections passing through it. Faults can be
+// changed at any time and are applied to both new and established connections.
+type Proxy struct {
+	address  string
+	upstream url.URL
+
+	faults atomic.Pointer[Faults]
+
+	mutex    sync.Mutex
+	active   map[*connection]struct{}
+	closed   bool
+	listener net.Listener
+
+	wg sync.WaitGroup
+}
+
+// Starts proxy on the loopback interface that forwards connections to the host
+// specified in the DSN.
+//
+// [Proxy.Close] method must be called when the proxy is no longer needed if
+// [Start] did not return an error.
+func Start(upstream url.URL) (*Proxy, error) {
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	prx := &Proxy{
+		address:  listener.Addr().String(),
+		upstream: upstream,
+		active:   make(map[*connection]struct{}),
+		listener: listener,
+	}
+
+	prx.faults.Store(&Faults{})
+
+	prx.wg.Add(1)
+
+	go prx.accept(listener)
+
+	return prx, nil
+}
+
+// Returns DSN in which the address of the database is replaced by the address of
+// the proxy.
+func (prx *Proxy) DSN() url.URL {
+	return rewrite.Address(prx.upstream, prx.address)
+}
+
+// Returns current faults.
+func (prx *Proxy) Faults() Faults {
+	return *prx.faults.Load()
+}
+
+// Replaces current faults with the specified ones.
+//
+// Refusing of connections is implemented by resetting of the accepted ones rather
+// than by closing of the listening socket, so the address of the proxy can not be
+// taken by another process while connections are refused.
+func (prx *Proxy) Set(faults Faults) error {
+	if err := faults.validate(); err != nil {
+		return err
+	}
+
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	if prx.closed {
+		return ErrProxyClosed
+	}
+
+	prx.faults.Store(&faults)
+
+	return nil
+}
+
+// Removes all faults.
+func (prx *Proxy) Heal() error {
+	return prx.Set(Faults{})
+}
+
+// Resets all established connections.
+func (prx *Proxy) ResetConnections() {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	for conn := range prx.active {
+		conn.reset()
+	}
+}
+
+// Stops accepting connections and closes the established ones.
+func (prx *Proxy) Close() error {
+	prx.mutex.Lock()
+
+	prx.closed = true
+
+	var err error
+
+	if prx.listener != nil {
+		err = prx.listener.Close()
+		prx.listener = nil
+	}
+
+	for conn := range prx.active {
+		conn.close()
+	}
+
+	prx.mutex.Unlock()
+
+	prx.wg.Wait()
+
+	return err
+}
+
+func (prx *Proxy) accept(listener net.Listener) {
+	defer prx.wg.Done()
+
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		if prx.faults.Load().Refuse {
+			abort(client)
+			continue
+		}
+
+		conn, added := prx.add(client)
+		if !added {
+			client.Close()
+			return
+		}
+
+		prx.wg.Add(1)
+
+		go func() {
+			defer prx.wg.Done()
+			defer prx.remove(conn)
+
+			conn.serve(prx.upstream.Host)
+		}()
+	}
+}
+
+// Closes the connection with sending RST.
+func abort(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+
+	conn.Close()
+}
+
+func (prx *Proxy) add(client net.Conn) (*connection, bool) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	if prx.closed {
+		return nil, false
+	}
+
+	conn := &connection{
+		client: client,
+		faults: &prx.faults,
+	}
+
+	prx.active[conn] = struct{}{}
+
+	return conn, true
+}
+
+func (prx *Proxy) remove(conn *connection) {
+	prx.mutex.Lock()
+	defer prx.mutex.Unlock()
+
+	delete(prx.active, conn)
+}
+
+type connection struct {
+	client net.Conn
+	faults *atomic.Pointer[Faults]
+
+	mutex  sync.Mutex
+	server net.Conn
+
+	transferred atomic.Int64
+}
+
+func (conn *connection) serve(upstream string) {
+	defer conn.close()
+
+	server, err := net.Dial("tcp", upstream)
+	if err != nil {
+		return
+	}
+
+	conn.mutex.Lock()
+	conn.server = server
+	conn.mutex.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer conn.close()
+
+		conn.pipe(server, conn.client)
+	}()
+
+	conn.pipe(conn.client, server)
+	conn.close()
+
+	<-done
+}
+
+func (conn *connection) pipe(src net.Conn, dst net.Conn) {
+	buffer := make([]byte, defaultChunkSize)
+
+	for {
+		faults := conn.faults.Load()
+
+		read, err := src.Read(buffer[:faults.chunkSize()])
+		if err != nil {
+			return
+		}
+
+		// Faults could have been changed while waiting for the data
+		faults = conn.faults.Load()
+
+		if faults.Blackhole {
+			continue
+		}
+
+		if delay := faults.delay(); delay > 0 {
+			time.Sleep(delay)
+		}
+
+		chunk := buffer[:read]
+
+		if faults.ResetAfter > 0 {
+			transferred := conn.transferred.Add(int64(read))
+
+			if excess := transferred - int64(faults.ResetAfter); excess >= 0 {
+				_, _ = dst.Write(chunk[:int64(read)-min(excess, int64(read))])
+
+				conn.reset()
+
+				return
+			}
+		} else {
+			conn.transferred.Add(int64(read))
+		}
+
+		// Chunk is delivered after the time it takes to transfer it at the limited
+		// rate
+		if faults.Bandwidth > 0 {
+			time.Sleep(time.Duration(read) * time.Second / time.Duration(faults.Bandwidth))
+		}
+
+		if _, err := dst.Write(chunk); err != nil {
+			return
+		}
+	}
+}
+
+// Closes both sides of the connection with sending RST.
+func (conn *connection) reset() {
+	for _, side := range conn.sides() {
+		abort(side)
+	}
+}
+
+func (conn *connection) close() {
+	for _, side := range conn.sides() {
+		side.Close()
+	}
+}
+
+func (conn *connection) sides() []net.Conn {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.server == nil {
+		return []net.Conn{conn.client}
+	}
+
+	return []net.Conn{conn.client, conn.server}
+}
@@ -0,0 +1,305 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/psql"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	cleanup := interceptor.Prepare()
+	defer cleanup()
+
+	m.Run()
+}
+
+func TestProxy(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	conn := dial(t, prx)
+
+	require.Equal(t, "ping", exchange(t, conn, "ping"))
+
+	require.NoError(t, prx.Set(Faults{Latency: 100 * time.Millisecond}))
+	require.Equal(t, Faults{Latency: 100 * time.Millisecond}, prx.Faults())
+
+	start := time.Now()
+
+	require.Equal(t, "ping", exchange(t, conn, "ping"))
+	// Latency is applied in both directions
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	require.NoError(t, prx.Heal())
+
+	start = time.Now()
+
+	require.Equal(t, "ping", exchange(t, conn, "ping"))
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestProxyJitter(t *testing.T) {
+	faults := Faults{
+		Latency: 100 * time.Millisecond,
+		Jitter:  50 * time.Millisecond,
+	}
+
+	for range 1000 {
+		delay := faults.delay()
+		require.GreaterOrEqual(t, delay, 50*time.Millisecond)
+		require.LessOrEqual(t, delay, 150*time.Millisecond)
+	}
+
+	faults = Faults{Jitter: time.Second}
+
+	for range 1000 {
+		require.GreaterOrEqual(t, faults.delay(), time.Duration(0))
+	}
+}
+
+func TestProxyBandwidth(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	require.NoError(t, prx.Set(Faults{Bandwidth: 1000}))
+
+	conn := dial(t, prx)
+
+	start := time.Now()
+
+	payload := string(make([]byte, 500))
+
+	require.Equal(t, payload, exchange(t, conn, payload))
+	// Transfers in both directions are overlapped
+	require.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestProxyResetAfter(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	require.NoError(t, prx.Set(Faults{ResetAfter: 6}))
+
+	conn := dial(t, prx)
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	received, err := io.ReadAll(conn)
+	require.Equal(t, "pi", string(received))
+	require.True(t, errors.Is(err, syscall.ECONNRESET), err)
+}
+
+func TestProxyBlackhole(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	conn := dial(t, prx)
+
+	require.NoError(t, prx.Set(Faults{Blackhole: true}))
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+
+	_, err = conn.Read(make([]byte, 4))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, prx.Heal())
+	require.NoError(t, conn.SetReadDeadline(time.Time{}))
+
+	require.Equal(t, "pong", exchange(t, conn, "pong"))
+}
+
+func TestProxyRefuse(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	established := dial(t, prx)
+
+	require.NoError(t, prx.Set(Faults{Refuse: true}))
+
+	dsn := prx.DSN()
+
+	refused, err := net.Dial("tcp", dsn.Host)
+	require.NoError(t, err)
+
+	_, err = refused.Read(make([]byte, 1))
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+	require.NoError(t, refused.Close())
+
+	require.Equal(t, "ping", exchange(t, established, "ping"))
+
+	require.NoError(t, prx.Heal())
+
+	require.Equal(t, "ping", exchange(t, dial(t, prx), "ping"))
+}
+
+func TestProxyResetConnections(t *testing.T) {
+	prx, err := Start(runEchoServer(t))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	conn := dial(t, prx)
+
+	require.Equal(t, "ping", exchange(t, conn, "ping"))
+
+	prx.ResetConnections()
+
+	_, err = conn.Read(make([]byte, 4))
+	require.Error(t, err)
+}
+
+func TestProxyWrongArgs(t *testing.T) {
+	prx, err := Start(url.URL{Host: "127.0.0.1:1"})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, prx.Set(Faults{Latency: -time.Second}), ErrLatencyNegative)
+	require.ErrorIs(t, prx.Set(Faults{Jitter: -time.Second}), ErrJitterNegative)
+	require.ErrorIs(t, prx.Set(Faults{Bandwidth: -1}), ErrBandwidthNegative)
+	require.ErrorIs(t, prx.Set(Faults{ResetAfter: -1}), ErrResetAfterNegative)
+
+	require.NoError(t, prx.Close())
+	require.ErrorIs(t, prx.Heal(), ErrProxyClosed)
+}
+
+func TestProxyPsql(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := psql.Run(t.Context(), "17", "postgres")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	prx, err := Start(dsns[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, prx.Close())
+	}()
+
+	dsn := prx.DSN()
+
+	conn, err := pgconn.Connect(t.Context(), dsn.String())
+	require.NoError(t, err)
+
+	_, err = conn.Exec(t.Context(), "SELECT 1").ReadAll()
+	require.NoError(t, err)
+
+	require.NoError(t, prx.Set(Faults{Blackhole: true}))
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "SELECT 1").ReadAll()
+	require.Error(t, err)
+
+	require.NoError(t, prx.Set(Faults{Refuse: true}))
+
+	_, err = pgconn.Connect(t.Context(), dsn.String())
+	require.Error(t, err)
+
+	require.NoError(t, prx.Heal())
+
+	conn, err = pgconn.Connect(t.Context(), dsn.String())
+	require.NoError(t, err)
+
+	_, err = conn.Exec(t.Context(), "SELECT 1").ReadAll()
+	require.NoError(t, err)
+
+	require.NoError(t, conn.Close(t.Context()))
+}
+
+func runEchoServer(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, listener.Close())
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return url.URL{Scheme: "postgres", Host: listener.Addr().String()}
+}
+
+func dial(t *testing.T, prx *Proxy) net.Conn {
+	dsn := prx.DSN()
+
+	conn, err := net.Dial("tcp", dsn.Host)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return conn
+}
+
+func exchange(t *testing.T, conn net.Conn, message string) string {
+	_, err := conn.Write([]byte(message))
+	require.NoError(t, err)
+
+	received := make([]byte, len(message))
+
+	_, err = io.ReadFull(conn, received)
+	require.NoError(t, err)
+
+	return string(received)
+}
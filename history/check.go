@@ -0,0 +1,364 @@
+package history
+
+import (
+	"cmp"
+	"hash/maphash"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Model of the object on which operations are performed.
+type Model[S any, I any, O any] struct {
+	// Splits the history into independent parts, e.g. by keys of registers, which
+	// are checked separately. If nil, history is checked as a whole
+	Partition func(history []Operation[I, O]) [][]Operation[I, O]
+	// Returns initial state of the object
+	Init func() S
+	// Returns whether the operation can be performed in the state and the state after
+	// it. For operations with status [StatusUnknown] output must be ignored. State
+	// must not be modified
+	Step func(state S, op Operation[I, O]) (bool, S)
+	// Returns whether the states are equal
+	Equal func(first S, second S) bool
+}
+
+// Result of the history check.
+type Result[I any, O any] struct {
+	Valid bool
+	// Minimal sub-history that violates the checked consistency: removal of any
+	// operation from it makes it valid. Empty if the history is valid
+	Violation []Operation[I, O]
+}
+
+func (result Result[I, O]) String() string {
+	if result.Valid {
+		return "history is valid"
+	}
+
+	lines := make([]string, 0, len(result.Violation)+1)
+
+	lines = append(lines, "history is not valid, violating operations:")
+
+	for _, op := range result.Violation {
+		lines = append(lines, "  "+op.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Checks whether the history is linearizable: there is a total order of
+// operations that respects their real-time order and is valid for the model.
+// Failed operations are not taken into account and operations with unknown result
+// may or may not take effect.
+func CheckLinearizable[S any, I any, O any](
+	model Model[S, I, O],
+	history []Operation[I, O],
+) Result[I, O] {
+	return check(model, history, true)
+}
+
+// Checks whether the history is serializable: there is a total order of operations
+// that is valid for the model. Unlike [CheckLinearizable], the real-time order of
+// operations is not taken into account.
+func CheckSerializable[S any, I any, O any](
+	model Model[S, I, O],
+	history []Operation[I, O],
+) Result[I, O] {
+	return check(model, history, false)
+}
+
+func check[S any, I any, O any](
+	model Model[S, I, O],
+	history []Operation[I, O],
+	realTime bool,
+) Result[I, O] {
+	performed := slices.DeleteFunc(slices.Clone(history), func(op Operation[I, O]) bool {
+		return op.Status == StatusFail
+	})
+
+	partitions := [][]Operation[I, O]{performed}
+
+	if model.Partition != nil {
+		partitions = model.Partition(performed)
+	}
+
+	for _, partition := range partitions {
+		if search(model, partition, realTime) {
+			continue
+		}
+
+		result := Result[I, O]{
+			Violation: minimize(model, partition, realTime),
+		}
+
+		return result
+	}
+
+	return Result[I, O]{Valid: true}
+}
+
+// Removes operations from the history one by one while it remains invalid.
+func minimize[S any, I any, O any](
+	model Model[S, I, O],
+	history []Operation[I, O],
+	realTime bool,
+) []Operation[I, O] {
+	minimal := slices.Clone(history)
+
+	for id := 0; id < len(minimal); {
+		candidate := slices.Delete(slices.Clone(minimal), id, id+1)
+
+		if search(model, candidate, realTime) {
+			id++
+			continue
+		}
+
+		minimal = candidate
+	}
+
+	return minimal
+}
+
+type entry struct {
+	id       int
+	call     bool
+	optional bool
+	time     int64
+
+	match *entry
+	next  *entry
+	prev  *entry
+}
+
+// Removes the call entry and its return entry from the list.
+func (ent *entry) lift() {
+	ent.prev.next = ent.next
+
+	if ent.next != nil {
+		ent.next.prev = ent.prev
+	}
+
+	ret := ent.match
+
+	ret.prev.next = ret.next
+
+	if ret.next != nil {
+		ret.next.prev = ret.prev
+	}
+}
+
+// Returns the call entry and its return entry to the list.
+func (ent *entry) unlift() {
+	ret := ent.match
+
+	ret.prev.next = ret
+
+	if ret.next != nil {
+		ret.next.prev = ret
+	}
+
+	ent.prev.next = ent
+
+	if ent.next != nil {
+		ent.next.prev = ent
+	}
+}
+
+// Builds list of call and return entries ordered by time. Returns of operations with
+// unknown result are placed at the end of the list as optional. Without real-time
+// order all calls precede all returns.
+func prepareEntries[I any, O any](history []Operation[I, O], realTime bool) *entry {
+	entries := make([]*entry, 0, 2*len(history)) //nolint:mnd // Call and return
+
+	for id, op := range history {
+		call := &entry{
+			id:   id,
+			call: true,
+			time: op.Invoke,
+		}
+
+		ret := &entry{
+			id:       id,
+			optional: op.Status == StatusUnknown,
+			time:     op.Complete,
+		}
+
+		if !realTime {
+			call.time = 0
+
+			if !ret.optional {
+				ret.time = math.MaxInt64 - 1
+			}
+		}
+
+		call.match = ret
+
+		entries = append(entries, call, ret)
+	}
+
+	slices.SortStableFunc(entries, func(first *entry, second *entry) int {
+		if compared := cmp.Compare(first.time, second.time); compared != 0 {
+			return compared
+		}
+
+		// Calls precede returns at the same time
+		if first.call != second.call {
+			if first.call {
+				return -1
+			}
+
+			return 1
+		}
+
+		return cmp.Compare(first.id, second.id)
+	})
+
+	head := &entry{}
+	last := head
+
+	for _, ent := range entries {
+		last.next = ent
+		ent.prev = last
+		last = ent
+	}
+
+	return head
+}
+
+type frame[S any] struct {
+	entry *entry
+	state S
+}
+
+type cached[S any] struct {
+	linearized bitset
+	state      S
+}
+
+// Searches for a valid order of operations with the algorithm of Wing and Gong
+// improved by Lowe.
+func search[S any, I any, O any](
+	model Model[S, I, O],
+	history []Operation[I, O],
+	realTime bool,
+) bool {
+	head := prepareEntries(history, realTime)
+
+	linearized := newBitset(len(history))
+	cache := make(map[uint64][]cached[S])
+	seed := maphash.MakeSeed()
+
+	var stack []frame[S]
+
+	state := model.Init()
+	ent := head.next
+
+	for head.next != nil {
+		if !ent.call {
+			// Only optional returns remain, so the rest operations may not take effect
+			if ent.optional {
+				return true
+			}
+
+			if len(stack) == 0 {
+				return false
+			}
+
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			linearized.clear(top.entry.id)
+			state = top.state
+			top.entry.unlift()
+
+			ent = top.entry.next
+
+			continue
+		}
+
+		valid, next := model.Step(state, history[ent.id])
+		if valid {
+			linearized.set(ent.id)
+
+			if addToCache(cache, seed, model, linearized, next) {
+				stack = append(stack, frame[S]{entry: ent, state: state})
+				state = next
+				ent.lift()
+
+				ent = head.next
+
+				continue
+			}
+
+			linearized.clear(ent.id)
+		}
+
+		ent = ent.next
+	}
+
+	return true
+}
+
+func addToCache[S any, I any, O any](
+	cache map[uint64][]cached[S],
+	seed maphash.Seed,
+	model Model[S, I, O],
+	linearized bitset,
+	state S,
+) bool {
+	hash := linearized.hash(seed)
+
+	for _, item := range cache[hash] {
+		if item.linearized.equal(linearized) && model.Equal(item.state, state) {
+			return false
+		}
+	}
+
+	item := cached[S]{
+		linearized: linearized.clone(),
+		state:      state,
+	}
+
+	cache[hash] = append(cache[hash], item)
+
+	return true
+}
+
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	const wordSize = 64
+
+	return make(bitset, (size+wordSize-1)/wordSize)
+}
+
+func (set bitset) set(id int) {
+	set[id/64] |= 1 << (id % 64)
+}
+
+func (set bitset) clear(id int) {
+	set[id/64] &^= 1 << (id % 64)
+}
+
+func (set bitset) equal(other bitset) bool {
+	return slices.Equal(set, other)
+}
+
+func (set bitset) clone() bitset {
+	return slices.Clone(set)
+}
+
+func (set bitset) hash(seed maphash.Seed) uint64 {
+	var hash maphash.Hash
+
+	hash.SetSeed(seed)
+
+	for _, word := range set {
+		for shift := 0; shift < 64; shift += 8 {
+			_ = hash.WriteByte(byte(word >> shift))
+		}
+	}
+
+	return hash.Sum64()
+}
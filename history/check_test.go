@@ -0,0 +1,259 @@
+package history
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func operation[I any, O any](
+	client int,
+	input I,
+	output O,
+	invoke int64,
+	complete int64,
+) Operation[I, O] {
+	op := Operation[I, O]{
+		Client:   client,
+		Input:    input,
+		Output:   output,
+		Status:   StatusOk,
+		Invoke:   invoke,
+		Complete: complete,
+	}
+
+	if complete == math.MaxInt64 {
+		op.Status = StatusUnknown
+	}
+
+	return op
+}
+
+func intOperation[I any](
+	client int,
+	input I,
+	output int64,
+	invoke int64,
+	complete int64,
+) Operation[I, int64] {
+	return operation(client, input, output, invoke, complete)
+}
+
+func write(key string, value int64) RegisterInput {
+	return RegisterInput{Kind: RegisterWrite, Key: key, Value: value}
+}
+
+func read(key string) RegisterInput {
+	return RegisterInput{Kind: RegisterRead, Key: key}
+}
+
+func TestCheckRegister(t *testing.T) {
+	model := RegisterModel(0)
+
+	// Concurrent write and reads that observe it in different orders
+	valid := []Operation[RegisterInput, int64]{
+		intOperation(0, write("x", 1), 0, 1, 4),
+		intOperation(1, read("x"), 0, 2, 3),
+		intOperation(2, read("x"), 1, 5, 6),
+		intOperation(1, write("y", 2), 0, 7, 8),
+		intOperation(2, read("y"), 2, 9, 10),
+	}
+
+	require.True(t, CheckLinearizable(model, valid).Valid)
+	require.True(t, CheckSerializable(model, valid).Valid)
+
+	// Stale read after the write completed
+	stale := []Operation[RegisterInput, int64]{
+		intOperation(0, write("y", 5), 0, 1, 2),
+		intOperation(0, write("x", 1), 0, 3, 4),
+		intOperation(1, read("y"), 5, 5, 6),
+		intOperation(1, read("x"), 0, 7, 8),
+	}
+
+	result := CheckLinearizable(model, stale)
+
+	require.False(t, result.Valid)
+	require.Equal(
+		t,
+		[]Operation[RegisterInput, int64]{
+			intOperation(0, write("x", 1), 0, 3, 4),
+			intOperation(1, read("x"), 0, 7, 8),
+		},
+		result.Violation,
+	)
+	require.Equal(
+		t,
+		"history is not valid, violating operations:\n"+
+			"  client 0: write x 1 -> 0 ok [3, 4]\n"+
+			"  client 1: read x -> 0 ok [7, 8]",
+		result.String(),
+	)
+
+	// Without real-time order the read can precede the write
+	require.True(t, CheckSerializable(model, stale).Valid)
+	require.Equal(t, "history is valid", CheckSerializable(model, stale).String())
+}
+
+func TestCheckRegisterUnknown(t *testing.T) {
+	model := RegisterModel(0)
+
+	// Write with unknown result may take effect at any time after invocation
+	visible := []Operation[RegisterInput, int64]{
+		intOperation(0, write("x", 1), 0, 1, math.MaxInt64),
+		intOperation(1, read("x"), 0, 2, 3),
+		intOperation(1, read("x"), 1, 10, 11),
+	}
+
+	require.True(t, CheckLinearizable(model, visible).Valid)
+
+	// Or may not take effect at all
+	invisible := []Operation[RegisterInput, int64]{
+		intOperation(0, write("x", 1), 0, 1, math.MaxInt64),
+		intOperation(1, read("x"), 0, 10, 11),
+	}
+
+	require.True(t, CheckLinearizable(model, invisible).Valid)
+
+	// But not before invocation
+	early := []Operation[RegisterInput, int64]{
+		intOperation(1, read("x"), 1, 1, 2),
+		intOperation(0, write("x", 1), 0, 3, math.MaxInt64),
+	}
+
+	require.False(t, CheckLinearizable(model, early).Valid)
+
+	// Failed write does not take effect
+	failed := intOperation(0, write("x", 1), 0, 1, 2)
+	failed.Status = StatusFail
+
+	phantom := []Operation[RegisterInput, int64]{
+		failed,
+		intOperation(1, read("x"), 1, 3, 4),
+	}
+
+	result := CheckLinearizable(model, phantom)
+
+	require.False(t, result.Valid)
+	require.Equal(t, phantom[1:], result.Violation)
+}
+
+func TestCheckCounter(t *testing.T) {
+	model := CounterModel(10)
+
+	add := CounterInput{Kind: CounterAdd, Delta: 5}
+	get := CounterInput{Kind: CounterRead}
+
+	valid := []Operation[CounterInput, int64]{
+		intOperation(0, add, 0, 1, 4),
+		intOperation(1, add, 0, 2, 5),
+		intOperation(2, get, 15, 3, 6),
+		intOperation(2, get, 20, 7, 8),
+		intOperation(0, add, 0, 9, math.MaxInt64),
+		intOperation(1, get, 20, 10, 11),
+		intOperation(1, get, 25, 12, 13),
+	}
+
+	require.True(t, CheckLinearizable(model, valid).Valid)
+
+	// Lost increment
+	lost := []Operation[CounterInput, int64]{
+		intOperation(2, get, 10, 1, 2),
+		intOperation(0, add, 0, 3, 4),
+		intOperation(1, add, 0, 5, 6),
+		intOperation(2, get, 15, 7, 8),
+	}
+
+	result := CheckLinearizable(model, lost)
+
+	require.False(t, result.Valid)
+	require.Equal(t, lost[1:], result.Violation)
+
+	// Without real-time order the read can precede the second increment
+	require.True(t, CheckSerializable(model, lost).Valid)
+}
+
+func TestCheckBank(t *testing.T) {
+	model := BankModel(map[string]int64{"a": 10, "b": 0})
+
+	transfer := BankInput{Kind: BankTransfer, From: "a", To: "b", Amount: 7}
+	get := BankInput{Kind: BankRead}
+
+	valid := []Operation[BankInput, BankOutput]{
+		operation(0, transfer, BankOutput{Transferred: true}, 1, 2),
+		operation(1, get, BankOutput{Balances: map[string]int64{"a": 3, "b": 7}}, 3, 4),
+		operation(0, transfer, BankOutput{Transferred: false}, 5, 6),
+		operation(0, transfer, BankOutput{}, 7, math.MaxInt64),
+		operation(1, get, BankOutput{Balances: map[string]int64{"a": 3, "b": 7}}, 8, 9),
+	}
+
+	require.True(t, CheckLinearizable(model, valid).Valid)
+
+	// Both transfers are performed, so the balance becomes negative
+	overdraft := []Operation[BankInput, BankOutput]{
+		operation(0, transfer, BankOutput{Transferred: true}, 1, 3),
+		operation(1, transfer, BankOutput{Transferred: true}, 2, 4),
+		operation(2, get, BankOutput{Balances: map[string]int64{"a": 3, "b": 7}}, 5, 6),
+	}
+
+	result := CheckSerializable(model, overdraft)
+
+	require.False(t, result.Valid)
+	require.Equal(t, overdraft[:2], result.Violation)
+
+	// Read observes money that is not in the bank
+	inflated := []Operation[BankInput, BankOutput]{
+		operation(0, transfer, BankOutput{Transferred: true}, 1, 2),
+		operation(1, get, BankOutput{Balances: map[string]int64{"a": 10, "b": 7}}, 3, 4),
+	}
+
+	require.False(t, CheckLinearizable(model, inflated).Valid)
+	require.Equal(t, inflated[1:], CheckLinearizable(model, inflated).Violation)
+
+	// Initial balances are not changed by the model
+	require.True(t, CheckLinearizable(model, valid).Valid)
+}
+
+func TestCheckEmpty(t *testing.T) {
+	require.True(t, CheckLinearizable(RegisterModel(0), nil).Valid)
+	require.True(t, CheckSerializable(CounterModel(0), nil).Valid)
+}
+
+func TestKindString(t *testing.T) {
+	require.Equal(t, "read", RegisterRead.String())
+	require.Equal(t, "write", RegisterWrite.String())
+	require.Equal(t, "invalid", RegisterKind(0).String())
+	require.Equal(t, "read", CounterRead.String())
+	require.Equal(t, "add", CounterAdd.String())
+	require.Equal(t, "invalid", CounterKind(0).String())
+	require.Equal(t, "read", BankRead.String())
+	require.Equal(t, "transfer", BankTransfer.String())
+	require.Equal(t, "invalid", BankKind(0).String())
+
+	require.Equal(t, "add 5", CounterInput{Kind: CounterAdd, Delta: 5}.String())
+	require.Equal(t, "read", CounterInput{Kind: CounterRead}.String())
+	require.Equal(
+		t,
+		"transfer 7 from a to b",
+		BankInput{Kind: BankTransfer, From: "a", To: "b", Amount: 7}.String(),
+	)
+	require.Equal(t, "read", BankInput{Kind: BankRead}.String())
+}
+
+func BenchmarkCheckLinearizable(b *testing.B) {
+	history := make([]Operation[CounterInput, int64], 0, 200)
+
+	for id := range int64(100) {
+		history = append(
+			history,
+			intOperation(0, CounterInput{Kind: CounterAdd, Delta: 1}, 0, 4*id+1, 4*id+3),
+			intOperation(1, CounterInput{Kind: CounterRead}, id+1, 4*id+2, 4*id+4),
+		)
+	}
+
+	for range b.N {
+		if !CheckLinearizable(CounterModel(0), history).Valid {
+			b.Fatal("history is not valid")
+		}
+	}
+}
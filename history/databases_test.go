@@ -0,0 +1,259 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/crdb"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/psql"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClients    = 4
+	testOperations = 50
+)
+
+func TestMain(m *testing.M) {
+	cleanup := interceptor.Prepare()
+	defer cleanup()
+
+	m.Run()
+}
+
+func TestPostgresBank(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := psql.Run(t.Context(), "17", "pgx5")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	db := openDB(t, dsns[0])
+
+	initial := map[string]int64{"a": 100, "b": 100, "c": 100}
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE accounts (id TEXT PRIMARY KEY, balance BIGINT)")
+	require.NoError(t, err)
+
+	for account, balance := range initial {
+		_, err = db.ExecContext(t.Context(), "INSERT INTO accounts VALUES ($1, $2)", account, balance)
+		require.NoError(t, err)
+	}
+
+	rec := NewRecorder[BankInput, BankOutput]()
+
+	runClients(func(client int, rnd *rand.Rand) {
+		if rnd.IntN(2) == 0 {
+			pending := rec.Invoke(client, BankInput{Kind: BankRead})
+			pending.Complete(readBalances(t.Context(), db))
+
+			return
+		}
+
+		input := BankInput{
+			Kind:   BankTransfer,
+			From:   string(rune('a' + rnd.IntN(3))),
+			To:     string(rune('a' + rnd.IntN(3))),
+			Amount: rnd.Int64N(50),
+		}
+
+		pending := rec.Invoke(client, input)
+		pending.Complete(transfer(t.Context(), db, input))
+	})
+
+	result := CheckLinearizable(BankModel(initial), rec.History())
+	require.True(t, result.Valid, result.String())
+}
+
+func TestCockroachRegister(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := crdb.RunCluster(t.Context(), "latest-v25.1", 3)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	dbs := make([]*sql.DB, len(dsns))
+
+	for id, dsn := range dsns {
+		dbs[id] = openDB(t, dsn)
+	}
+
+	_, err = dbs[0].ExecContext(
+		t.Context(),
+		"CREATE TABLE registers (id STRING PRIMARY KEY, value INT8)",
+	)
+	require.NoError(t, err)
+
+	rec := NewRecorder[RegisterInput, int64]()
+
+	runClients(func(client int, rnd *rand.Rand) {
+		db := dbs[client%len(dbs)]
+		key := strconv.Itoa(rnd.IntN(3))
+
+		if rnd.IntN(2) == 0 {
+			pending := rec.Invoke(client, RegisterInput{Kind: RegisterRead, Key: key})
+
+			var value int64
+
+			err := db.QueryRowContext(
+				t.Context(),
+				"SELECT COALESCE((SELECT value FROM registers WHERE id = $1), 0)",
+				key,
+			).Scan(&value)
+
+			pending.Complete(value, err)
+
+			return
+		}
+
+		input := RegisterInput{Kind: RegisterWrite, Key: key, Value: rnd.Int64N(10)}
+
+		pending := rec.Invoke(client, input)
+
+		_, err := db.ExecContext(t.Context(), "UPSERT INTO registers VALUES ($1, $2)", key, input.Value)
+
+		pending.Complete(0, classify(err))
+	})
+
+	// Single-key operations of CockroachDB are linearizable
+	result := CheckLinearizable(RegisterModel(0), rec.History())
+	require.True(t, result.Valid, result.String())
+}
+
+func openDB(t *testing.T, dsn url.URL) *sql.DB {
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	return db
+}
+
+func runClients(operate func(client int, rnd *rand.Rand)) {
+	var wg sync.WaitGroup
+
+	for client := range testClients {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			//nolint:gosec // Cryptographic strength is not needed
+			rnd := rand.New(rand.NewPCG(uint64(client), uint64(time.Now().UnixNano())))
+
+			for range testOperations {
+				operate(client, rnd)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func readBalances(ctx context.Context, db *sql.DB) (BankOutput, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, balance FROM accounts")
+	if err != nil {
+		return BankOutput{}, err
+	}
+
+	defer rows.Close()
+
+	output := BankOutput{Balances: make(map[string]int64)}
+
+	for rows.Next() {
+		var (
+			account string
+			balance int64
+		)
+
+		if err := rows.Scan(&account, &balance); err != nil {
+			return BankOutput{}, err
+		}
+
+		output.Balances[account] = balance
+	}
+
+	return output, rows.Err()
+}
+
+func transfer(ctx context.Context, db *sql.DB, input BankInput) (BankOutput, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return BankOutput{}, fmt.Errorf("%w: %w", ErrOperationFailed, err)
+	}
+
+	var balance int64
+
+	if err := tx.QueryRowContext(
+		ctx,
+		"SELECT balance FROM accounts WHERE id = $1",
+		input.From,
+	).Scan(&balance); err != nil {
+		return BankOutput{}, errors.Join(classify(err), tx.Rollback())
+	}
+
+	if balance < input.Amount {
+		return BankOutput{}, classify(tx.Commit())
+	}
+
+	statements := []string{
+		"UPDATE accounts SET balance = balance - $2 WHERE id = $1",
+		"UPDATE accounts SET balance = balance + $2 WHERE id = $1",
+	}
+
+	for id, account := range []string{input.From, input.To} {
+		if _, err := tx.ExecContext(ctx, statements[id], account, input.Amount); err != nil {
+			return BankOutput{}, errors.Join(classify(err), tx.Rollback())
+		}
+	}
+
+	return BankOutput{Transferred: true}, classify(tx.Commit())
+}
+
+// Errors reported by the server before the commit mean that the operation was not
+// performed, errors of the connection mean that its result is unknown.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		return fmt.Errorf("%w: %w", ErrOperationFailed, err)
+	}
+
+	return err
+}
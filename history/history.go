@@ -0,0 +1,187 @@
+// Records histories of operations performed by concurrent clients against databases
+// and checks them for linearizability and serializability.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Operation was definitely not performed, e.g. transaction was rolled back. Errors
+// passed to the [Pending.Complete] method must wrap it to mark operations as failed.
+var ErrOperationFailed = errors.New("operation was definitely not performed")
+
+// Status of the operation.
+type Status int
+
+const (
+	// Operation has been performed and its output is known
+	StatusOk Status = iota + 1
+	// Operation was definitely not performed
+	StatusFail
+	// Operation may or may not have been performed, e.g. connection was broken
+	// while waiting for the commit
+	StatusUnknown
+)
+
+func (status Status) String() string {
+	switch status {
+	case StatusOk:
+		return "ok"
+	case StatusFail:
+		return "fail"
+	case StatusUnknown:
+		return "unknown"
+	}
+
+	return "invalid"
+}
+
+// Operation recorded in the history.
+type Operation[I any, O any] struct {
+	Client int
+	Input  I
+	// Valid if the status is [StatusOk]
+	Output O
+	Status Status
+
+	// Logical time of the invocation and completion. Completion of the operation with
+	// [StatusUnknown] is infinitely distant
+	Invoke   int64
+	Complete int64
+
+	// Wall clock time of the invocation and completion, only for reports
+	InvokeTime   time.Time
+	CompleteTime time.Time
+}
+
+func (op Operation[I, O]) String() string {
+	text := fmt.Sprintf("client %d: %+v", op.Client, op.Input)
+
+	if op.Status == StatusOk {
+		text += fmt.Sprintf(" -> %+v", op.Output)
+	}
+
+	text += fmt.Sprintf(" %s [%d, ", op.Status, op.Invoke)
+
+	if op.Complete == math.MaxInt64 {
+		return text + "∞)"
+	}
+
+	return text + fmt.Sprintf("%d]", op.Complete)
+}
+
+// Records invocations and completions of operations performed by concurrent
+// clients.
+type Recorder[I any, O any] struct {
+	clock      int64
+	mutex      sync.Mutex
+	operations []Operation[I, O]
+}
+
+// Creates the recorder of the history.
+func NewRecorder[I any, O any]() *Recorder[I, O] {
+	rec := &Recorder[I, O]{}
+
+	return rec
+}
+
+// Operation that was invoked but not yet completed.
+type Pending[I any, O any] struct {
+	id  int
+	rec *Recorder[I, O]
+}
+
+// Records invocation of the operation by the client. Completion of the operation
+// must be recorded using the returned value. Operations of one client must not
+// overlap.
+func (rec *Recorder[I, O]) Invoke(client int, input I) *Pending[I, O] {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	rec.clock++
+
+	op := Operation[I, O]{
+		Client:     client,
+		Input:      input,
+		Status:     StatusUnknown,
+		Invoke:     rec.clock,
+		Complete:   math.MaxInt64,
+		InvokeTime: time.Now(),
+	}
+
+	rec.operations = append(rec.operations, op)
+
+	pending := &Pending[I, O]{
+		id:  len(rec.operations) - 1,
+		rec: rec,
+	}
+
+	return pending
+}
+
+// Records successful completion of the operation with the specified output.
+func (pnd *Pending[I, O]) Ok(output O) {
+	pnd.rec.complete(pnd.id, output, StatusOk)
+}
+
+// Records that the operation was definitely not performed.
+func (pnd *Pending[I, O]) Fail() {
+	var output O
+
+	pnd.rec.complete(pnd.id, output, StatusFail)
+}
+
+// Records that the operation may or may not have been performed.
+func (pnd *Pending[I, O]) Unknown() {
+	var output O
+
+	pnd.rec.complete(pnd.id, output, StatusUnknown)
+}
+
+// Records completion of the operation depending on the error. If the error is nil,
+// operation is completed successfully, if the error wraps [ErrOperationFailed],
+// operation is failed, otherwise its result is unknown.
+func (pnd *Pending[I, O]) Complete(output O, err error) {
+	switch {
+	case err == nil:
+		pnd.Ok(output)
+	case errors.Is(err, ErrOperationFailed):
+		pnd.Fail()
+	default:
+		pnd.Unknown()
+	}
+}
+
+func (rec *Recorder[I, O]) complete(id int, output O, status Status) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	op := &rec.operations[id]
+
+	op.Status = status
+	op.CompleteTime = time.Now()
+
+	// Operation with unknown result may take effect at any time after invocation
+	if status == StatusUnknown {
+		return
+	}
+
+	rec.clock++
+
+	op.Output = output
+	op.Complete = rec.clock
+}
+
+// Returns the recorded history. Operations that were not completed have status
+// [StatusUnknown].
+func (rec *Recorder[I, O]) History() []Operation[I, O] {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	return slices.Clone(rec.operations)
+}
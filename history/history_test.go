@@ -0,0 +1,112 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder[RegisterInput, int64]()
+
+	write := rec.Invoke(0, RegisterInput{Kind: RegisterWrite, Key: "x", Value: 1})
+	read := rec.Invoke(1, RegisterInput{Kind: RegisterRead, Key: "x"})
+
+	write.Ok(0)
+	read.Complete(1, nil)
+
+	failed := rec.Invoke(0, RegisterInput{Kind: RegisterWrite, Key: "x", Value: 2})
+	failed.Complete(0, fmt.Errorf("%w: rolled back", ErrOperationFailed))
+
+	unknown := rec.Invoke(0, RegisterInput{Kind: RegisterWrite, Key: "x", Value: 3})
+	unknown.Complete(0, errors.New("connection reset"))
+
+	rec.Invoke(1, RegisterInput{Kind: RegisterRead, Key: "x"})
+
+	history := rec.History()
+
+	require.Len(t, history, 5)
+
+	require.Equal(t, StatusOk, history[0].Status)
+	require.Equal(t, int64(1), history[0].Invoke)
+	require.Equal(t, int64(3), history[0].Complete)
+
+	require.Equal(t, StatusOk, history[1].Status)
+	require.Equal(t, int64(1), history[1].Output)
+	require.Equal(t, int64(2), history[1].Invoke)
+	require.Equal(t, int64(4), history[1].Complete)
+	require.False(t, history[1].CompleteTime.Before(history[1].InvokeTime))
+
+	require.Equal(t, StatusFail, history[2].Status)
+	require.Equal(t, StatusUnknown, history[3].Status)
+	require.Equal(t, int64(math.MaxInt64), history[3].Complete)
+
+	require.Equal(t, StatusUnknown, history[4].Status)
+	require.True(t, history[4].CompleteTime.IsZero())
+
+	require.Equal(t, "client 1: read x -> 1 ok [2, 4]", history[1].String())
+	require.Equal(t, "client 0: write x 3 unknown [7, ∞)", history[3].String())
+}
+
+func TestRecorderConcurrent(t *testing.T) {
+	const (
+		clients    = 8
+		operations = 100
+	)
+
+	rec := NewRecorder[CounterInput, int64]()
+
+	var (
+		mutex sync.Mutex
+		value int64
+		wg    sync.WaitGroup
+	)
+
+	for client := range clients {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for id := range operations {
+				if id%2 == 0 {
+					pending := rec.Invoke(client, CounterInput{Kind: CounterAdd, Delta: 1})
+
+					mutex.Lock()
+					value++
+					mutex.Unlock()
+
+					pending.Ok(0)
+
+					continue
+				}
+
+				pending := rec.Invoke(client, CounterInput{Kind: CounterRead})
+
+				mutex.Lock()
+				read := value
+				mutex.Unlock()
+
+				pending.Ok(read)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	history := rec.History()
+
+	require.Len(t, history, clients*operations)
+	require.True(t, CheckLinearizable(CounterModel(0), history).Valid)
+}
+
+func TestStatusString(t *testing.T) {
+	require.Equal(t, "ok", StatusOk.String())
+	require.Equal(t, "fail", StatusFail.String())
+	require.Equal(t, "unknown", StatusUnknown.String())
+	require.Equal(t, "invalid", Status(0).String())
+}
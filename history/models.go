@@ -0,0 +1,239 @@
+package history
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Kinds of operations on the register.
+const (
+	RegisterRead RegisterKind = iota + 1
+	RegisterWrite
+)
+
+// Kind of the operation on the register.
+type RegisterKind int
+
+func (kind RegisterKind) String() string {
+	switch kind {
+	case RegisterRead:
+		return "read"
+	case RegisterWrite:
+		return "write"
+	}
+
+	return "invalid"
+}
+
+// Input of the operation on the register. Value is written by the write operation.
+type RegisterInput struct {
+	Kind  RegisterKind
+	Key   string
+	Value int64
+}
+
+func (input RegisterInput) String() string {
+	if input.Kind == RegisterWrite {
+		return fmt.Sprintf("%s %s %d", input.Kind, input.Key, input.Value)
+	}
+
+	return fmt.Sprintf("%s %s", input.Kind, input.Key)
+}
+
+// Model of registers identified by keys with the specified initial value. Output of
+// the read operation is the value of the register. Registers are checked
+// independently of each other.
+func RegisterModel(initial int64) Model[int64, RegisterInput, int64] {
+	step := func(state int64, op Operation[RegisterInput, int64]) (bool, int64) {
+		switch op.Input.Kind {
+		case RegisterWrite:
+			return true, op.Input.Value
+		case RegisterRead:
+			return op.Status == StatusUnknown || op.Output == state, state
+		}
+
+		return false, state
+	}
+
+	model := Model[int64, RegisterInput, int64]{
+		Partition: partitionByKey,
+		Init:      func() int64 { return initial },
+		Step:      step,
+		Equal:     func(first int64, second int64) bool { return first == second },
+	}
+
+	return model
+}
+
+func partitionByKey[O any](history []Operation[RegisterInput, O]) [][]Operation[RegisterInput, O] {
+	keys := make(map[string]int)
+
+	var partitions [][]Operation[RegisterInput, O]
+
+	for _, op := range history {
+		id, exists := keys[op.Input.Key]
+		if !exists {
+			id = len(partitions)
+			keys[op.Input.Key] = id
+
+			partitions = append(partitions, nil)
+		}
+
+		partitions[id] = append(partitions[id], op)
+	}
+
+	return partitions
+}
+
+// Kinds of operations on the counter.
+const (
+	CounterRead CounterKind = iota + 1
+	CounterAdd
+)
+
+// Kind of the operation on the counter.
+type CounterKind int
+
+func (kind CounterKind) String() string {
+	switch kind {
+	case CounterRead:
+		return "read"
+	case CounterAdd:
+		return "add"
+	}
+
+	return "invalid"
+}
+
+// Input of the operation on the counter. Delta is added by the add operation.
+type CounterInput struct {
+	Kind  CounterKind
+	Delta int64
+}
+
+func (input CounterInput) String() string {
+	if input.Kind == CounterAdd {
+		return fmt.Sprintf("%s %d", input.Kind, input.Delta)
+	}
+
+	return input.Kind.String()
+}
+
+// Model of the counter with the specified initial value. Output of the read
+// operation is the value of the counter.
+func CounterModel(initial int64) Model[int64, CounterInput, int64] {
+	step := func(state int64, op Operation[CounterInput, int64]) (bool, int64) {
+		switch op.Input.Kind {
+		case CounterAdd:
+			return true, state + op.Input.Delta
+		case CounterRead:
+			return op.Status == StatusUnknown || op.Output == state, state
+		}
+
+		return false, state
+	}
+
+	model := Model[int64, CounterInput, int64]{
+		Init:  func() int64 { return initial },
+		Step:  step,
+		Equal: func(first int64, second int64) bool { return first == second },
+	}
+
+	return model
+}
+
+// Kinds of operations on the bank.
+const (
+	BankRead BankKind = iota + 1
+	BankTransfer
+)
+
+// Kind of the operation on the bank.
+type BankKind int
+
+func (kind BankKind) String() string {
+	switch kind {
+	case BankRead:
+		return "read"
+	case BankTransfer:
+		return "transfer"
+	}
+
+	return "invalid"
+}
+
+// Input of the operation on the bank. Amount is transferred from one account to
+// another by the transfer operation.
+type BankInput struct {
+	Kind   BankKind
+	From   string
+	To     string
+	Amount int64
+}
+
+func (input BankInput) String() string {
+	if input.Kind == BankTransfer {
+		return fmt.Sprintf("%s %d from %s to %s", input.Kind, input.Amount, input.From, input.To)
+	}
+
+	return input.Kind.String()
+}
+
+// Output of the operation on the bank.
+type BankOutput struct {
+	// Balances of all accounts read by the read operation
+	Balances map[string]int64
+	// Whether the transfer was performed or rejected due to insufficient balance
+	Transferred bool
+}
+
+// Model of the bank with the specified initial balances of all accounts. Transfer
+// is performed only if the balance of the source account is sufficient, otherwise
+// it is rejected, so balances never become negative and their total never
+// changes.
+func BankModel(initial map[string]int64) Model[map[string]int64, BankInput, BankOutput] {
+	initial = maps.Clone(initial)
+
+	step := func(
+		state map[string]int64,
+		op Operation[BankInput, BankOutput],
+	) (bool, map[string]int64) {
+		switch op.Input.Kind {
+		case BankTransfer:
+			sufficient := state[op.Input.From] >= op.Input.Amount
+
+			// Transfer with unknown result is either performed, if it is possible, or
+			// has no effect
+			if op.Status == StatusUnknown && !sufficient {
+				return true, state
+			}
+
+			if op.Status == StatusOk && !op.Output.Transferred {
+				return !sufficient, state
+			}
+
+			if !sufficient {
+				return false, state
+			}
+
+			next := maps.Clone(state)
+
+			next[op.Input.From] -= op.Input.Amount
+			next[op.Input.To] += op.Input.Amount
+
+			return true, next
+		case BankRead:
+			return op.Status == StatusUnknown || maps.Equal(op.Output.Balances, state), state
+		}
+
+		return false, state
+	}
+
+	model := Model[map[string]int64, BankInput, BankOutput]{
+		Init:  func() map[string]int64 { return initial },
+		Step:  step,
+		Equal: maps.Equal[map[string]int64, map[string]int64],
+	}
+
+	return model
+}
@@ -0,0 +1,37 @@
+// Kills and starts containers to simulate crashes of their processes.
+package crash
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const signal = "KILL"
+
+// Kills all processes of the container with the SIGKILL signal, so they have no
+// chance to shut down gracefully. Established connections are reset.
+func Kill(ctx context.Context, containerID string) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.ContainerKill(ctx, containerID, signal)
+}
+
+// Starts the killed container. Volumes of the container are preserved, but its
+// tmpfs mounts are empty and its ports can be published on other host ports.
+func Start(ctx context.Context, containerID string) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.ContainerStart(ctx, containerID, container.StartOptions{})
+}
@@ -4,5 +4,8 @@ package env
 const (
 	prefix = "ILLUSION_"
 
+	ChaosSeed           = prefix + "CHAOS_SEED"
 	InterceptorUpstream = prefix + "INTERCEPTOR_UPSTREAM"
+	Keep                = prefix + "KEEP"
+	UpdateGolden        = prefix + "UPDATE_GOLDEN"
 )
@@ -0,0 +1,124 @@
+// Executes commands in containers and separates their output.
+package execute
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+)
+
+// Exit code can be not yet available right after the output of the command is
+// closed.
+const inspectInterval = 10 * time.Millisecond
+
+var ErrExitCodeNonZero = errors.New("exit code is non-zero")
+
+// Target of command execution. Implemented by the containers and by the targets of
+// wait strategies.
+type Executor interface {
+	Exec(ctx context.Context, cmd []string, opts ...tcexec.ProcessOption) (int, io.Reader, error)
+}
+
+// Executes command in container and returns its standard output. Standard error is
+// included in the returned error when the command exit code is non-zero.
+func Run(
+	ctx context.Context,
+	container Executor,
+	cmd []string,
+	opts ...tcexec.ProcessOption,
+) ([]byte, error) {
+	code, reader, err := container.Exec(ctx, cmd, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, reader); err != nil {
+		return nil, err
+	}
+
+	if code != 0 {
+		return nil, fmt.Errorf(
+			"%w: %d: %s",
+			ErrExitCodeNonZero,
+			code,
+			bytes.TrimSpace(stderr.Bytes()),
+		)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Executes command in container and writes its standard output to the specified
+// writer as it is produced, without buffering the whole output in memory or in the
+// container. Standard error is included in the returned error when the command
+// exit code is non-zero.
+func Stream(ctx context.Context, containerID string, cmd []string, stdout io.Writer) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	created, err := client.ContainerExecCreate(
+		ctx,
+		containerID,
+		container.ExecOptions{
+			Cmd:          cmd,
+			AttachStdout: true,
+			AttachStderr: true,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	attached, err := client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer attached.Close()
+
+	var stderr bytes.Buffer
+
+	if _, err := stdcopy.StdCopy(stdout, &stderr, attached.Reader); err != nil {
+		return err
+	}
+
+	for {
+		inspected, err := client.ContainerExecInspect(ctx, created.ID)
+		if err != nil {
+			return err
+		}
+
+		if !inspected.Running {
+			if inspected.ExitCode != 0 {
+				return fmt.Errorf(
+					"%w: %d: %s",
+					ErrExitCodeNonZero,
+					inspected.ExitCode,
+					bytes.TrimSpace(stderr.Bytes()),
+				)
+			}
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(inspectInterval):
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package execute
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestRun(t *testing.T) {
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image: "alpine:latest",
+			Cmd: []string{
+				"sh",
+				"-c",
+				"sleep 60",
+			},
+		},
+		Started: true,
+	}
+
+	container, err := testcontainers.GenericContainer(t.Context(), req)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, testcontainers.TerminateContainer(container))
+	}()
+
+	output, err := Run(t.Context(), container, []string{"sh", "-c", "echo out; echo err >&2"})
+	require.NoError(t, err)
+	require.Equal(t, "out\n", string(output))
+
+	output, err = Run(t.Context(), container, []string{"sh", "-c", "echo out; echo err >&2; exit 3"})
+	require.ErrorIs(t, err, ErrExitCodeNonZero)
+	require.ErrorContains(t, err, "err")
+	require.Nil(t, output)
+}
+
+func TestStream(t *testing.T) {
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image: "alpine:latest",
+			Cmd: []string{
+				"sh",
+				"-c",
+				"sleep 60",
+			},
+		},
+		Started: true,
+	}
+
+	container, err := testcontainers.GenericContainer(t.Context(), req)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, testcontainers.TerminateContainer(container))
+	}()
+
+	var output bytes.Buffer
+
+	// Output is larger than the buffers of the pipes between the command and the
+	// daemon, so it is not produced unless it is read while the command runs
+	err = Stream(
+		t.Context(),
+		container.GetContainerID(),
+		[]string{"sh", "-c", "head -c 16777216 /dev/zero; echo err >&2"},
+		&output,
+	)
+	require.NoError(t, err)
+	require.Equal(t, 16777216, output.Len())
+
+	output.Reset()
+
+	err = Stream(
+		t.Context(),
+		container.GetContainerID(),
+		[]string{"sh", "-c", "echo out; echo err >&2; exit 3"},
+		&output,
+	)
+	require.ErrorIs(t, err, ErrExitCodeNonZero)
+	require.ErrorContains(t, err, "err")
+	require.Equal(t, "out\n", output.String())
+}
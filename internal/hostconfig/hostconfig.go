@@ -0,0 +1,21 @@
+// Chains modifiers of the host configuration of container requests.
+package hostconfig
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Adds the modifier of the host configuration to the request. Modifier is called
+// after the modifiers added earlier.
+func Add(req *testcontainers.GenericContainerRequest, modifier func(config *container.HostConfig)) {
+	previous := req.HostConfigModifier
+
+	req.HostConfigModifier = func(config *container.HostConfig) {
+		if previous != nil {
+			previous(config)
+		}
+
+		modifier(config)
+	}
+}
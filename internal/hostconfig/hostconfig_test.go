@@ -0,0 +1,27 @@
+package hostconfig
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestAdd(t *testing.T) {
+	var req testcontainers.GenericContainerRequest
+
+	Add(&req, func(config *container.HostConfig) {
+		config.DNS = append(config.DNS, "10.0.0.1")
+	})
+
+	Add(&req, func(config *container.HostConfig) {
+		config.DNS = append(config.DNS, "10.0.0.2")
+	})
+
+	var config container.HostConfig
+
+	req.HostConfigModifier(&config)
+
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, config.DNS)
+}
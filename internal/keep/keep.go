@@ -0,0 +1,242 @@
+// Keeps environments of failed tests running for debugging and removes them later.
+package keep
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/env"
+	"github.com/akramarenkov/illusion/internal/nilness"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// Value of the environment variable that enables keeping of environments of
+	// failed tests
+	OnFailure = "on-failure"
+
+	label = "illusion.keep"
+
+	// Label by which resources are reaped by Ryuk at the end of the session
+	sessionLabel = "org.testcontainers.sessionId"
+)
+
+// Returns whether environments of failed tests should be kept.
+func Enabled() bool {
+	return os.Getenv(env.Keep) == OnFailure
+}
+
+// Returns labels of the network of the environment of the specified kind, e.g.
+// psql or crdb.
+//
+// Network is still reaped by Ryuk, but not while kept containers are connected to
+// it.
+func NetworkLabels(kind string) map[string]string {
+	return map[string]string{label: kind}
+}
+
+// Marks the container and volumes created for it by the testcontainers as belonging
+// to the environment of the specified kind and excludes them from reaping by Ryuk
+// at the end of the session.
+//
+// Must be called after all other modifiers of the request are set.
+func PrepareRequest(kind string, req *testcontainers.GenericContainerRequest) {
+	previousConfig := req.ConfigModifier
+
+	req.ConfigModifier = func(config *container.Config) {
+		if previousConfig != nil {
+			previousConfig(config)
+		}
+
+		config.Labels = relabel(kind, config.Labels)
+	}
+
+	previousHostConfig := req.HostConfigModifier
+
+	req.HostConfigModifier = func(config *container.HostConfig) {
+		if previousHostConfig != nil {
+			previousHostConfig(config)
+		}
+
+		for id := range config.Mounts {
+			// Volumes mounted bypassing the testcontainers are not touched
+			if options := config.Mounts[id].VolumeOptions; options != nil {
+				options.Labels = relabel(kind, options.Labels)
+			}
+		}
+	}
+}
+
+func relabel(kind string, labels map[string]string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+
+	delete(labels, sessionLabel)
+
+	labels[label] = kind
+
+	return labels
+}
+
+// Returns names of the containers. Containers that were not created are skipped.
+func Names(ctx context.Context, containers ...testcontainers.Container) []string {
+	names := make([]string, 0, len(containers))
+
+	for _, ctr := range containers {
+		if nilness.IsNil(ctr) {
+			continue
+		}
+
+		info, err := ctr.Inspect(ctx)
+		if err != nil {
+			names = append(names, ctr.GetContainerID())
+			continue
+		}
+
+		names = append(names, strings.TrimPrefix(info.Name, "/"))
+	}
+
+	return names
+}
+
+// Kept environment.
+type Environment struct {
+	DSNs       []url.URL
+	Containers []string
+	Network    string
+	Volumes    []string
+	// Directories with files generated for the environment on the host
+	Dirs []string
+}
+
+// Returns the description of the environment with the command that removes it.
+func (environ Environment) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("environment of the failed test is kept")
+
+	for _, dsn := range environ.DSNs {
+		builder.WriteString("\n  dsn: " + dsn.String())
+	}
+
+	for _, name := range environ.Containers {
+		builder.WriteString("\n  container: " + name)
+	}
+
+	if environ.Network != "" {
+		builder.WriteString("\n  network: " + environ.Network)
+	}
+
+	for _, name := range environ.Volumes {
+		builder.WriteString("\n  volume: " + name)
+	}
+
+	for _, dir := range environ.Dirs {
+		builder.WriteString("\n  directory: " + dir)
+	}
+
+	builder.WriteString("\n  remove with: " + environ.Command())
+
+	return builder.String()
+}
+
+// Returns the shell command that removes the environment. Containers are removed
+// first, since the network and volumes cannot be removed while they are in use.
+func (environ Environment) Command() string {
+	commands := make([]string, 0, 4) //nolint:mnd // Containers, network, volumes and dirs
+
+	if len(environ.Containers) != 0 {
+		commands = append(commands, "docker rm -f -v "+strings.Join(environ.Containers, " "))
+	}
+
+	if environ.Network != "" {
+		commands = append(commands, "docker network rm "+environ.Network)
+	}
+
+	if len(environ.Volumes) != 0 {
+		commands = append(commands, "docker volume rm "+strings.Join(environ.Volumes, " "))
+	}
+
+	if len(environ.Dirs) != 0 {
+		quoted := make([]string, 0, len(environ.Dirs))
+
+		for _, dir := range environ.Dirs {
+			quoted = append(quoted, quote(dir))
+		}
+
+		commands = append(commands, "rm -rf "+strings.Join(quoted, " "))
+	}
+
+	return strings.Join(commands, " && ")
+}
+
+func quote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Removes containers, networks and volumes of all kept environments of the
+// specified kind. Resources removed concurrently are not considered an error.
+func Remove(ctx context.Context, kind string) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	args := filters.NewArgs(filters.Arg("label", label+"="+kind))
+
+	containers, err := client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, ctr := range containers {
+		options := container.RemoveOptions{
+			Force:         true,
+			RemoveVolumes: true,
+		}
+
+		errs = append(errs, ignoreNotFound(client.ContainerRemove(ctx, ctr.ID, options)))
+	}
+
+	networks, err := client.NetworkList(ctx, network.ListOptions{Filters: args})
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, netwk := range networks {
+		errs = append(errs, ignoreNotFound(client.NetworkRemove(ctx, netwk.ID)))
+	}
+
+	volumes, err := client.VolumeList(ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, vol := range volumes.Volumes {
+		errs = append(errs, ignoreNotFound(client.VolumeRemove(ctx, vol.Name, true)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func ignoreNotFound(err error) error {
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
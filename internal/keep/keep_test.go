@@ -0,0 +1,126 @@
+package keep
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/env"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv(env.Keep, "")
+	require.False(t, Enabled())
+
+	t.Setenv(env.Keep, "always")
+	require.False(t, Enabled())
+
+	t.Setenv(env.Keep, OnFailure)
+	require.True(t, Enabled())
+}
+
+func TestPrepareRequest(t *testing.T) {
+	modified := false
+
+	req := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			HostConfigModifier: func(config *container.HostConfig) {
+				modified = true
+
+				config.Mounts = append(config.Mounts, mount.Mount{Source: "persistent"})
+			},
+		},
+	}
+
+	PrepareRequest("psql", &req)
+
+	config := &container.Config{
+		Labels: map[string]string{
+			sessionLabel:                "session",
+			"org.testcontainers.golang": "true",
+		},
+	}
+
+	req.ConfigModifier(config)
+
+	require.Equal(
+		t,
+		map[string]string{label: "psql", "org.testcontainers.golang": "true"},
+		config.Labels,
+	)
+
+	hostConfig := &container.HostConfig{
+		Mounts: []mount.Mount{
+			{
+				Source:        "data",
+				VolumeOptions: &mount.VolumeOptions{Labels: map[string]string{sessionLabel: "session"}},
+			},
+			{
+				Source:        "archive",
+				VolumeOptions: &mount.VolumeOptions{},
+			},
+		},
+	}
+
+	req.HostConfigModifier(hostConfig)
+
+	require.True(t, modified)
+	require.Equal(t, map[string]string{label: "psql"}, hostConfig.Mounts[0].VolumeOptions.Labels)
+	require.Equal(t, map[string]string{label: "psql"}, hostConfig.Mounts[1].VolumeOptions.Labels)
+	require.Nil(t, hostConfig.Mounts[2].VolumeOptions)
+
+	config = &container.Config{}
+
+	PrepareRequest("crdb", &req)
+	req.ConfigModifier(config)
+
+	require.Equal(t, map[string]string{label: "crdb"}, config.Labels)
+}
+
+func TestNetworkLabels(t *testing.T) {
+	require.Equal(t, map[string]string{label: "crdb"}, NetworkLabels("crdb"))
+}
+
+func TestNamesNil(t *testing.T) {
+	var ctr *testcontainers.DockerContainer
+
+	require.Empty(t, Names(t.Context(), nil, ctr))
+}
+
+func TestEnvironment(t *testing.T) {
+	environ := Environment{
+		DSNs: []url.URL{
+			{Scheme: "postgres", Host: "localhost:32768", Path: "/postgres"},
+			{Scheme: "postgres", Host: "localhost:32769", Path: "/postgres"},
+		},
+		Containers: []string{"node-0", "node-1"},
+		Network:    "network",
+		Volumes:    []string{"archive"},
+		Dirs:       []string{"/tmp/it's tls"},
+	}
+
+	command := "docker rm -f -v node-0 node-1 && docker network rm network && " +
+		"docker volume rm archive && rm -rf '/tmp/it'\\''s tls'"
+
+	require.Equal(t, command, environ.Command())
+	require.Equal(
+		t,
+		"environment of the failed test is kept\n"+
+			"  dsn: postgres://localhost:32768/postgres\n"+
+			"  dsn: postgres://localhost:32769/postgres\n"+
+			"  container: node-0\n"+
+			"  container: node-1\n"+
+			"  network: network\n"+
+			"  volume: archive\n"+
+			"  directory: /tmp/it's tls\n"+
+			"  remove with: "+command,
+		environ.String(),
+	)
+
+	require.Equal(t, "docker network rm network", Environment{Network: "network"}.Command())
+	require.Empty(t, Environment{}.Command())
+}
@@ -0,0 +1,123 @@
+// Streams logs of containers line by line and reads their last lines.
+package logs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/akramarenkov/illusion/internal/nilness"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Consumes logs of a container, splits them into lines and passes the lines to the
+// logging function with the prefix and to the writer without it. Incomplete last
+// line is held until it is completed or [Consumer.Flush] or [Consumer.Close] is
+// called.
+type Consumer struct {
+	logf   func(format string, args ...any)
+	prefix string
+	writer io.Writer
+
+	mutex   sync.Mutex
+	partial []byte
+}
+
+// Creates the consumer. Logging function and writer are optional.
+func NewConsumer(prefix string, logf func(format string, args ...any), writer io.Writer) *Consumer {
+	csm := &Consumer{
+		logf:   logf,
+		prefix: prefix,
+		writer: writer,
+	}
+
+	return csm
+}
+
+// Implements the [testcontainers.LogConsumer] interface.
+func (csm *Consumer) Accept(log testcontainers.Log) {
+	csm.mutex.Lock()
+	defer csm.mutex.Unlock()
+
+	csm.partial = append(csm.partial, log.Content...)
+
+	for {
+		end := bytes.IndexByte(csm.partial, '\n')
+		if end < 0 {
+			return
+		}
+
+		csm.write(csm.partial[:end])
+		csm.partial = csm.partial[end+1:]
+	}
+}
+
+// Writes the incomplete last line.
+func (csm *Consumer) Flush() {
+	csm.mutex.Lock()
+	defer csm.mutex.Unlock()
+
+	if len(csm.partial) == 0 {
+		return
+	}
+
+	csm.write(csm.partial)
+	csm.partial = nil
+}
+
+// Writes the incomplete last line and drops all subsequent logs, e.g. of the
+// container that is kept running after the end of the test.
+func (csm *Consumer) Close() {
+	csm.Flush()
+
+	csm.mutex.Lock()
+	defer csm.mutex.Unlock()
+
+	csm.logf = nil
+	csm.writer = nil
+}
+
+func (csm *Consumer) write(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	if csm.logf != nil {
+		csm.logf("%s%s", csm.prefix, line)
+	}
+
+	if csm.writer != nil {
+		// Logs are auxiliary, so the write errors are ignored
+		_, _ = csm.writer.Write(append(line, '\n'))
+	}
+}
+
+// Returns the last lines of the container logs. Container may be nil if it was not
+// created.
+func Tail(ctx context.Context, ctr testcontainers.Container, lines int) ([]string, error) {
+	if nilness.IsNil(ctr) || lines <= 0 {
+		return nil, nil
+	}
+
+	reader, err := ctr.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	logs, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimRight(string(logs), "\r\n")
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	split := strings.Split(trimmed, "\n")
+
+	return split[max(len(split)-lines, 0):], nil
+}
@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestConsumer(t *testing.T) {
+	var (
+		logged []string
+		file   bytes.Buffer
+	)
+
+	logf := func(format string, args ...any) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	csm := NewConsumer("node 0: ", logf, &file)
+
+	csm.Accept(testcontainers.Log{Content: []byte("first\nsec")})
+	csm.Accept(testcontainers.Log{Content: []byte("ond\r\n")})
+	csm.Accept(testcontainers.Log{Content: []byte("\nthird")})
+
+	require.Equal(t, []string{"node 0: first", "node 0: second", "node 0: "}, logged)
+	require.Equal(t, "first\nsecond\n\n", file.String())
+
+	csm.Flush()
+	csm.Flush()
+
+	require.Equal(t, []string{"node 0: first", "node 0: second", "node 0: ", "node 0: third"}, logged)
+	require.Equal(t, "first\nsecond\n\nthird\n", file.String())
+
+	csm.Accept(testcontainers.Log{Content: []byte("fourth")})
+	csm.Close()
+	csm.Accept(testcontainers.Log{Content: []byte("\nfifth\n")})
+	csm.Close()
+
+	require.Equal(
+		t,
+		[]string{"node 0: first", "node 0: second", "node 0: ", "node 0: third", "node 0: fourth"},
+		logged,
+	)
+	require.Equal(t, "first\nsecond\n\nthird\nfourth\n", file.String())
+
+	NewConsumer("", nil, nil).Accept(testcontainers.Log{Content: []byte("line\n")})
+}
+
+func TestTailNil(t *testing.T) {
+	lines, err := Tail(t.Context(), nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, lines)
+
+	var ctr *testcontainers.DockerContainer
+
+	lines, err = Tail(t.Context(), ctr, 10)
+	require.NoError(t, err)
+	require.Empty(t, lines)
+}
@@ -0,0 +1,183 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/parallel"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	dirMode = 0o755
+)
+
+var (
+	ErrLogfNil            = errors.New("logging function is nil")
+	ErrLogDirEmpty        = errors.New("directory for log files is empty")
+	ErrLogTailNotPositive = errors.New("number of log lines is zero or negative")
+)
+
+// Options of the logs of the nodes.
+type Opts struct {
+	// Logging function to which lines of logs are streamed
+	Logf func(format string, args ...any)
+	// Directory with files to which logs are streamed
+	Dir string
+	// Number of last lines of logs added to the error of the start
+	Tail int
+}
+
+// Sets the logging function.
+func (opts *Opts) SetLogf(logf func(format string, args ...any)) error {
+	if logf == nil {
+		return ErrLogfNil
+	}
+
+	opts.Logf = logf
+
+	return nil
+}
+
+// Sets the directory with files.
+func (opts *Opts) SetDir(dir string) error {
+	if dir == "" {
+		return ErrLogDirEmpty
+	}
+
+	opts.Dir = dir
+
+	return nil
+}
+
+// Sets the number of last lines.
+func (opts *Opts) SetTail(lines int) error {
+	if lines <= 0 {
+		return ErrLogTailNotPositive
+	}
+
+	opts.Tail = lines
+
+	return nil
+}
+
+// Streams logs of the nodes to the logging function and files and adds their last
+// lines to the error of the start.
+type Nodes struct {
+	opts Opts
+
+	consumers []*Consumer
+	files     []*os.File
+	hostnames map[int]string
+}
+
+// Creates the logs of the nodes.
+func NewNodes(opts Opts) *Nodes {
+	nds := &Nodes{
+		opts:      opts,
+		hostnames: make(map[int]string),
+	}
+
+	return nds
+}
+
+// Prepares streaming of logs of the node with the specified index and hostname.
+// Lines are prefixed with the index and hostname of the node in the logging
+// function and written without prefix to the file named index-hostname.log.
+func (nds *Nodes) Prepare(id int, hostname string, req *testcontainers.GenericContainerRequest) error {
+	nds.hostnames[id] = hostname
+
+	if nds.opts.Logf == nil && nds.opts.Dir == "" {
+		return nil
+	}
+
+	var writer io.Writer
+
+	if nds.opts.Dir != "" {
+		if err := os.MkdirAll(nds.opts.Dir, dirMode); err != nil {
+			return err
+		}
+
+		file, err := os.Create(filepath.Join(nds.opts.Dir, strconv.Itoa(id)+"-"+hostname+".log"))
+		if err != nil {
+			return err
+		}
+
+		nds.files = append(nds.files, file)
+		writer = file
+	}
+
+	consumer := NewConsumer(describe(id, hostname)+": ", nds.opts.Logf, writer)
+
+	nds.consumers = append(nds.consumers, consumer)
+
+	req.LogConsumerCfg = &testcontainers.LogConsumerConfig{
+		Consumers: []testcontainers.LogConsumer{consumer},
+	}
+
+	return nil
+}
+
+// Flushes incomplete lines of logs, stops consuming them and closes log files. Must
+// be called after the nodes are terminated or kept.
+func (nds *Nodes) Close() error {
+	for _, consumer := range nds.consumers {
+		consumer.Close()
+	}
+
+	nds.consumers = nil
+
+	for len(nds.files) != 0 {
+		if err := nds.files[0].Close(); err != nil {
+			return err
+		}
+
+		nds.files = nds.files[1:]
+	}
+
+	return nil
+}
+
+// Adds the last lines of logs of the nodes to the error of the start.
+func AddTails[Type parallel.Running](
+	ctx context.Context,
+	nds *Nodes,
+	nodes []Type,
+	cause error,
+) error {
+	if nds.opts.Tail == 0 {
+		return cause
+	}
+
+	var tails strings.Builder
+
+	for id, node := range nodes {
+		lines, err := Tail(ctx, node.Get(), nds.opts.Tail)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+
+		tails.WriteString("\nlast lines of logs of " + describe(id, nds.hostnames[id]) + ":")
+
+		for _, line := range lines {
+			tails.WriteString("\n  " + line)
+		}
+	}
+
+	if tails.Len() == 0 {
+		return cause
+	}
+
+	return fmt.Errorf("%w%s", cause, tails.String())
+}
+
+func describe(id int, hostname string) string {
+	return "node " + strconv.Itoa(id) + " (" + hostname + ")"
+}
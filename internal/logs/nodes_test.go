@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/parallel"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type testNode struct{}
+
+func (testNode) Get() testcontainers.Container {
+	return nil
+}
+
+var _ parallel.Running = testNode{}
+
+func TestNodes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+	nds := NewNodes(Opts{Dir: dir, Tail: 10})
+
+	var request testcontainers.GenericContainerRequest
+
+	require.NoError(t, nds.Prepare(1, "host", &request))
+	require.FileExists(t, filepath.Join(dir, "1-host.log"))
+	require.Len(t, request.LogConsumerCfg.Consumers, 1)
+
+	request.LogConsumerCfg.Consumers[0].Accept(testcontainers.Log{Content: []byte("partial")})
+
+	require.NoError(t, nds.Close())
+	require.NoError(t, nds.Close())
+
+	content, err := os.ReadFile(filepath.Join(dir, "1-host.log"))
+	require.NoError(t, err)
+	require.Equal(t, "partial\n", string(content))
+
+	cause := errors.New("readiness timeout")
+
+	require.Equal(t, cause, AddTails(t.Context(), nds, []testNode{{}}, cause))
+}
+
+func TestNodesDisabled(t *testing.T) {
+	nds := NewNodes(Opts{})
+
+	var request testcontainers.GenericContainerRequest
+
+	require.NoError(t, nds.Prepare(0, "host", &request))
+	require.Nil(t, request.LogConsumerCfg)
+	require.NoError(t, nds.Close())
+
+	cause := errors.New("readiness timeout")
+
+	require.Equal(t, cause, AddTails(t.Context(), nds, []testNode{{}}, cause))
+}
+
+func TestOpts(t *testing.T) {
+	var opts Opts
+
+	require.ErrorIs(t, opts.SetLogf(nil), ErrLogfNil)
+	require.ErrorIs(t, opts.SetDir(""), ErrLogDirEmpty)
+	require.ErrorIs(t, opts.SetTail(0), ErrLogTailNotPositive)
+	require.Equal(t, Opts{}, opts)
+
+	require.NoError(t, opts.SetLogf(t.Logf))
+	require.NoError(t, opts.SetDir("logs"))
+	require.NoError(t, opts.SetTail(10))
+	require.NotNil(t, opts.Logf)
+	require.Equal(t, "logs", opts.Dir)
+	require.Equal(t, 10, opts.Tail)
+}
@@ -0,0 +1,83 @@
+// Describes network degradation rules applied by tc netem.
+package netem
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const percentMax = 100
+
+var (
+	ErrDelayNegative       = errors.New("delay or jitter is negative")
+	ErrPercentOutOfRange   = errors.New("percentage is out of range [0, 100]")
+	ErrReorderWithoutDelay = errors.New("reordering requires non-zero delay")
+)
+
+// Network degradation rules applied by tc netem to the packets sent from one node
+// to another. Percentages are specified in the range [0, 100].
+type Rules struct {
+	Delay     time.Duration
+	Jitter    time.Duration
+	Loss      float64
+	Reorder   float64
+	Duplicate float64
+	Corrupt   float64
+}
+
+// Validates the rules.
+func Validate(rules Rules) error {
+	if rules.Delay < 0 || rules.Jitter < 0 {
+		return ErrDelayNegative
+	}
+
+	for _, percent := range []float64{rules.Loss, rules.Reorder, rules.Duplicate, rules.Corrupt} {
+		if percent < 0 || percent > percentMax {
+			return fmt.Errorf("%w: %v", ErrPercentOutOfRange, percent)
+		}
+	}
+
+	// Packets are reordered by sending some of them without delay
+	if rules.Reorder != 0 && rules.Delay == 0 {
+		return ErrReorderWithoutDelay
+	}
+
+	return nil
+}
+
+// Returns arguments of the netem qdisc that correspond to the rules.
+func Args(rules Rules) []string {
+	args := make([]string, 0)
+
+	if rules.Delay != 0 || rules.Jitter != 0 {
+		args = append(args, "delay", formatDuration(rules.Delay))
+
+		if rules.Jitter != 0 {
+			args = append(args, formatDuration(rules.Jitter))
+		}
+	}
+
+	percents := []struct {
+		name  string
+		value float64
+	}{
+		{name: "loss", value: rules.Loss},
+		{name: "reorder", value: rules.Reorder},
+		{name: "duplicate", value: rules.Duplicate},
+		{name: "corrupt", value: rules.Corrupt},
+	}
+
+	for _, percent := range percents {
+		if percent.value != 0 {
+			args = append(args, percent.name, strconv.FormatFloat(percent.value, 'f', -1, 64)+"%")
+		}
+	}
+
+	return args
+}
+
+func formatDuration(duration time.Duration) string {
+	return strconv.FormatInt(duration.Microseconds(), 10) + "us"
+}
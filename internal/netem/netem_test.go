@@ -0,0 +1,43 @@
+package netem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgs(t *testing.T) {
+	rules := Rules{
+		Delay:     100 * time.Millisecond,
+		Jitter:    1500 * time.Microsecond,
+		Loss:      5,
+		Reorder:   25.5,
+		Duplicate: 1,
+		Corrupt:   0.1,
+	}
+
+	require.NoError(t, Validate(rules))
+	require.Equal(
+		t,
+		[]string{
+			"delay", "100000us", "1500us",
+			"loss", "5%",
+			"reorder", "25.5%",
+			"duplicate", "1%",
+			"corrupt", "0.1%",
+		},
+		Args(rules),
+	)
+
+	require.Equal(t, []string{"loss", "100%"}, Args(Rules{Loss: 100}))
+	require.Empty(t, Args(Rules{}))
+}
+
+func TestValidate(t *testing.T) {
+	require.ErrorIs(t, Validate(Rules{Delay: -time.Second}), ErrDelayNegative)
+	require.ErrorIs(t, Validate(Rules{Jitter: -time.Second}), ErrDelayNegative)
+	require.ErrorIs(t, Validate(Rules{Loss: -1}), ErrPercentOutOfRange)
+	require.ErrorIs(t, Validate(Rules{Corrupt: 101}), ErrPercentOutOfRange)
+	require.ErrorIs(t, Validate(Rules{Reorder: 10}), ErrReorderWithoutDelay)
+}
@@ -0,0 +1,20 @@
+// Detects containers that were not created.
+package nilness
+
+import (
+	"reflect"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Returns whether the container is nil, including a nil pointer to the container
+// returned by the testcontainers when the container was not created.
+func IsNil(ctr testcontainers.Container) bool {
+	if ctr == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(ctr)
+
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
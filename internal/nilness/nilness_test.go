@@ -0,0 +1,16 @@
+package nilness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestIsNil(t *testing.T) {
+	var ctr *testcontainers.DockerContainer
+
+	require.True(t, IsNil(nil))
+	require.True(t, IsNil(ctr))
+	require.False(t, IsNil(&testcontainers.DockerContainer{}))
+}
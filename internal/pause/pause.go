@@ -0,0 +1,71 @@
+// Pauses and unpauses containers with the freezer cgroup.
+package pause
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+var ErrDurationNotPositive = errors.New("pause duration is not positive")
+
+// Pauses all processes of the container. Established connections are kept, but
+// nothing is read from or written to them until the container is unpaused.
+func Pause(ctx context.Context, containerID string) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.ContainerPause(ctx, containerID)
+}
+
+// Unpauses all processes of the container.
+func Unpause(ctx context.Context, containerID string) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	return client.ContainerUnpause(ctx, containerID)
+}
+
+// Pauses the container and unpauses it in the background after the specified
+// duration or earlier if the context is canceled.
+//
+// Result of the unpausing is sent to the returned channel, after which the channel
+// is closed.
+func For(ctx context.Context, containerID string, duration time.Duration) (<-chan error, error) {
+	if duration <= 0 {
+		return nil, ErrDurationNotPositive
+	}
+
+	if err := Pause(ctx, containerID); err != nil {
+		return nil, err
+	}
+
+	resumed := make(chan error, 1)
+
+	go func() {
+		defer close(resumed)
+
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+
+		// Container must be unpaused even if the context is canceled
+		resumed <- Unpause(context.WithoutCancel(ctx), containerID)
+	}()
+
+	return resumed, nil
+}
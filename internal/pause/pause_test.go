@@ -0,0 +1,17 @@
+package pause
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForWrongDuration(t *testing.T) {
+	resumed, err := For(t.Context(), "", 0)
+	require.ErrorIs(t, err, ErrDurationNotPositive)
+	require.Nil(t, resumed)
+
+	resumed, err = For(t.Context(), "", -1)
+	require.ErrorIs(t, err, ErrDurationNotPositive)
+	require.Nil(t, resumed)
+}
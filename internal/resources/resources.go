@@ -0,0 +1,209 @@
+// Limits resources of containers, changes their CPU quota while they are running
+// and detects containers killed due to out of memory.
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akramarenkov/illusion/internal/nilness"
+	"github.com/akramarenkov/illusion/internal/parallel"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+var (
+	ErrCPUsNegative        = errors.New("number of CPUs is negative")
+	ErrMemoryNegative      = errors.New("memory limit is negative")
+	ErrSwapWithoutMemory   = errors.New("swap is limited without memory limit")
+	ErrPidsLimitNegative   = errors.New("pids limit is negative")
+	ErrCPUsNotPositive     = errors.New("number of CPUs is zero or negative")
+	ErrNodeOOMKilled       = errors.New("node was killed due to out of memory")
+	ErrOOMKilledNotChecked = errors.New("out of memory kills were not checked")
+)
+
+const (
+	nanoCPUsPerCPU = 1e9
+)
+
+// Resource limits of a node. Zero value of a limit means no limit.
+type Resources struct {
+	// Number of CPUs available to the node, may be fractional, e.g. 0.5
+	CPUs float64
+	// CPUs on which the node is allowed to run, e.g. 0-2 or 0,1
+	CPUSet string
+	// Memory limit in bytes
+	Memory int64
+	// Amount of swap in bytes available in addition to the memory. Used only with
+	// the memory limit. Zero disables swap, negative value means unlimited swap
+	Swap int64
+	// Maximum number of processes and threads
+	PidsLimit int64
+}
+
+// Validates the limits.
+func Validate(res Resources) error {
+	if res.CPUs < 0 {
+		return ErrCPUsNegative
+	}
+
+	if res.Memory < 0 {
+		return ErrMemoryNegative
+	}
+
+	if res.Swap != 0 && res.Memory == 0 {
+		return ErrSwapWithoutMemory
+	}
+
+	if res.PidsLimit < 0 {
+		return ErrPidsLimitNegative
+	}
+
+	return nil
+}
+
+// Applies the limits to the configuration of the container.
+func Apply(res Resources, config *container.HostConfig) {
+	if res.CPUs != 0 {
+		config.NanoCPUs = int64(res.CPUs * nanoCPUsPerCPU)
+	}
+
+	if res.CPUSet != "" {
+		config.CpusetCpus = res.CPUSet
+	}
+
+	if res.Memory != 0 {
+		config.Memory = res.Memory
+		// Total amount of memory and swap
+		config.MemorySwap = res.Memory + res.Swap
+
+		if res.Swap < 0 {
+			config.MemorySwap = -1
+		}
+	}
+
+	if res.PidsLimit != 0 {
+		config.PidsLimit = &res.PidsLimit
+	}
+}
+
+// Node was killed by the kernel due to exceeding the memory limit.
+type OOMKilledError struct {
+	// Index of the node
+	Node int
+	// Error caused by the kill, e.g. readiness timeout
+	Err error
+}
+
+func (err *OOMKilledError) Error() string {
+	text := fmt.Sprintf("%s: %d", ErrNodeOOMKilled, err.Node)
+
+	if err.Err != nil {
+		text += ": " + err.Err.Error()
+	}
+
+	return text
+}
+
+func (err *OOMKilledError) Unwrap() []error {
+	if err.Err == nil {
+		return []error{ErrNodeOOMKilled}
+	}
+
+	return []error{ErrNodeOOMKilled, err.Err}
+}
+
+// Replaces the error caused by the node killed due to out of memory with the
+// [OOMKilledError] error. If no node was killed, the cause is returned as is.
+//
+// Nodes whose containers were not created are skipped.
+func CheckOOMKilled[Type parallel.Running](ctx context.Context, nodes []Type, cause error) error {
+	for id, node := range nodes {
+		killed, err := oomKilled(ctx, node.Get())
+		if err != nil {
+			if cause != nil {
+				return cause
+			}
+
+			return fmt.Errorf("%w: %w", ErrOOMKilledNotChecked, err)
+		}
+
+		if killed {
+			return &OOMKilledError{Node: id, Err: cause}
+		}
+	}
+
+	return cause
+}
+
+func oomKilled(ctx context.Context, ctr testcontainers.Container) (bool, error) {
+	if nilness.IsNil(ctr) {
+		return false, nil
+	}
+
+	info, err := ctr.Inspect(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if info.State == nil {
+		return false, nil
+	}
+
+	return info.State.OOMKilled, nil
+}
+
+// Changes the number of CPUs available to the running container. Number may be
+// fractional, e.g. 0.1.
+func SetCPUs(ctx context.Context, containerID string, number float64) error {
+	if number <= 0 {
+		return ErrCPUsNotPositive
+	}
+
+	return updateCPUs(ctx, containerID, number)
+}
+
+// Restores the number of CPUs available to the running container to the one
+// specified by the limits or removes the limit if limits are not specified.
+func RestoreCPUs(ctx context.Context, containerID string, res *Resources) error {
+	var number float64
+
+	if res != nil {
+		number = res.CPUs
+	}
+
+	return updateCPUs(ctx, containerID, number)
+}
+
+// If the number is zero, quota is set to the number of CPUs of the Docker host,
+// which is equivalent to no limit, since Docker does not allow to remove the quota
+// of a running container.
+func updateCPUs(ctx context.Context, containerID string, number float64) error {
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if number == 0 {
+		info, err := client.Info(ctx)
+		if err != nil {
+			return err
+		}
+
+		number = float64(info.NCPU)
+	}
+
+	update := container.UpdateConfig{
+		Resources: container.Resources{
+			NanoCPUs: int64(number * nanoCPUsPerCPU),
+		},
+	}
+
+	_, err = client.ContainerUpdate(ctx, containerID, update)
+
+	return err
+}
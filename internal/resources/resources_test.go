@@ -0,0 +1,92 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type testNode struct {
+	container testcontainers.Container
+}
+
+func (tnd testNode) Get() testcontainers.Container {
+	return tnd.container
+}
+
+func TestValidate(t *testing.T) {
+	require.ErrorIs(t, Validate(Resources{CPUs: -1}), ErrCPUsNegative)
+	require.ErrorIs(t, Validate(Resources{Memory: -1}), ErrMemoryNegative)
+	require.ErrorIs(t, Validate(Resources{Swap: 1}), ErrSwapWithoutMemory)
+	require.ErrorIs(t, Validate(Resources{PidsLimit: -1}), ErrPidsLimitNegative)
+	require.NoError(t, Validate(Resources{CPUs: 0.5, Memory: 1 << 20, Swap: -1}))
+}
+
+func TestApply(t *testing.T) {
+	var first, second, third container.HostConfig
+
+	Apply(Resources{CPUSet: "0", Memory: 1 << 20, Swap: -1}, &first)
+	Apply(Resources{CPUs: 2, PidsLimit: 64}, &second)
+	Apply(Resources{Memory: 1 << 20}, &third)
+
+	require.Equal(
+		t,
+		container.HostConfig{
+			Resources: container.Resources{
+				CpusetCpus: "0",
+				Memory:     1 << 20,
+				MemorySwap: -1,
+			},
+		},
+		first,
+	)
+
+	pidsLimit := int64(64)
+
+	require.Equal(
+		t,
+		container.HostConfig{
+			Resources: container.Resources{
+				NanoCPUs:  2_000_000_000,
+				PidsLimit: &pidsLimit,
+			},
+		},
+		second,
+	)
+
+	require.Equal(t, int64(1<<20), third.MemorySwap)
+}
+
+func TestOOMKilledError(t *testing.T) {
+	cause := errors.New("readiness timeout")
+
+	err := error(&OOMKilledError{Node: 2, Err: cause})
+
+	require.ErrorIs(t, err, ErrNodeOOMKilled)
+	require.ErrorIs(t, err, cause)
+	require.Equal(t, ErrNodeOOMKilled.Error()+": 2: readiness timeout", err.Error())
+
+	err = &OOMKilledError{Node: 1}
+
+	require.ErrorIs(t, err, ErrNodeOOMKilled)
+	require.Equal(t, ErrNodeOOMKilled.Error()+": 1", err.Error())
+}
+
+func TestCheckOOMKilledNil(t *testing.T) {
+	cause := errors.New("readiness timeout")
+
+	var ctr *testcontainers.DockerContainer
+
+	nodes := []testNode{{}, {container: ctr}}
+
+	require.NoError(t, CheckOOMKilled(t.Context(), nodes, nil))
+	require.ErrorIs(t, CheckOOMKilled(t.Context(), nodes, cause), cause)
+}
+
+func TestSetCPUsWrongNumber(t *testing.T) {
+	require.ErrorIs(t, SetCPUs(t.Context(), "", 0), ErrCPUsNotPositive)
+	require.ErrorIs(t, SetCPUs(t.Context(), "", -1), ErrCPUsNotPositive)
+}
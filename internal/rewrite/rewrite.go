@@ -0,0 +1,18 @@
+// Internal package with helpers for rewriting DSNs returned by the database setup
+// packages.
+package rewrite
+
+import "net/url"
+
+// Returns copy of the DSN in which the host and port are replaced with the
+// specified address.
+func Address(dsn url.URL, address string) url.URL {
+	if dsn.User != nil {
+		user := *dsn.User
+		dsn.User = &user
+	}
+
+	dsn.Host = address
+
+	return dsn
+}
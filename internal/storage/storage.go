@@ -0,0 +1,136 @@
+// Limits block I/O of containers and fills their data directories to simulate
+// running out of disk space.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+)
+
+var ErrThrottleDeviceEmpty = errors.New("path to the throttled block device is empty")
+
+const fillerFile = "illusion-filler"
+
+// Limits of block I/O of a node. Zero value of a limit means no limit.
+//
+// Limits are applied by the kernel to the specified block device of the host, so
+// the device must be the one on which the data of the node is placed, e.g. the
+// device that holds the data root of the Docker daemon. Write limits affect only
+// direct I/O and writeback accounted by the cgroup of the container.
+type IOThrottle struct {
+	// Path to the block device of the host, e.g. /dev/sda
+	Device string
+
+	// Bytes per second
+	ReadBps  uint64
+	WriteBps uint64
+
+	// Operations per second
+	ReadIOps  uint64
+	WriteIOps uint64
+}
+
+// Validates the limits.
+func Validate(throttle IOThrottle) error {
+	if throttle.Device == "" {
+		return ErrThrottleDeviceEmpty
+	}
+
+	return nil
+}
+
+// Applies the limits to the configuration of the container.
+func Apply(throttle IOThrottle, config *container.HostConfig) {
+	limits := []struct {
+		rate    uint64
+		devices *[]*blkiodev.ThrottleDevice
+	}{
+		{rate: throttle.ReadBps, devices: &config.BlkioDeviceReadBps},
+		{rate: throttle.WriteBps, devices: &config.BlkioDeviceWriteBps},
+		{rate: throttle.ReadIOps, devices: &config.BlkioDeviceReadIOps},
+		{rate: throttle.WriteIOps, devices: &config.BlkioDeviceWriteIOps},
+	}
+
+	for _, limit := range limits {
+		if limit.rate == 0 {
+			continue
+		}
+
+		device := &blkiodev.ThrottleDevice{
+			Path: throttle.Device,
+			Rate: limit.rate,
+		}
+
+		*limit.devices = append(*limit.devices, device)
+	}
+}
+
+// Limits of block I/O of the nodes. Zero value means no limits.
+type Throttles struct {
+	all   *IOThrottle
+	nodes map[int]IOThrottle
+}
+
+// Sets the limits for the nodes with the specified indices. If indices are not
+// specified, limits are used for all nodes for which they are not set
+// individually. Negative index is reported with the specified error.
+func (ths *Throttles) Set(throttle IOThrottle, nodes []int, errNodeNotFound error) error {
+	if err := Validate(throttle); err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		ths.all = &throttle
+		return nil
+	}
+
+	if ths.nodes == nil {
+		ths.nodes = make(map[int]IOThrottle, len(nodes))
+	}
+
+	for _, id := range nodes {
+		if id < 0 {
+			return fmt.Errorf("%w: %d", errNodeNotFound, id)
+		}
+
+		ths.nodes[id] = throttle
+	}
+
+	return nil
+}
+
+// Returns the limits of the node with the specified index or nil if the node is not
+// limited.
+func (ths Throttles) Node(id int) *IOThrottle {
+	if throttle, exists := ths.nodes[id]; exists {
+		return &throttle
+	}
+
+	return ths.all
+}
+
+// Returns command that fills the data directory with a filler file until there is
+// no space left on it.
+func FillCommand(dataDir string) []string {
+	// Dd exits with an error when space runs out, so success is determined by the
+	// existence of the filler file
+	cmd := []string{
+		"sh",
+		"-c",
+		`dd if=/dev/zero of="$1" bs=64k status=none 2>/dev/null; test -f "$1"`,
+		"sh",
+		path.Join(dataDir, fillerFile),
+	}
+
+	return cmd
+}
+
+// Returns command that removes the filler file created by the command returned by
+// the [FillCommand] from the data directory.
+func FreeCommand(dataDir string) []string {
+	return []string{"rm", "-f", path.Join(dataDir, fillerFile)}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottles(t *testing.T) {
+	errNodeNotFound := errors.New("node was not found")
+
+	var throttles Throttles
+
+	require.Nil(t, throttles.Node(0))
+
+	require.ErrorIs(t, throttles.Set(IOThrottle{}, nil, errNodeNotFound), ErrThrottleDeviceEmpty)
+	require.ErrorIs(
+		t,
+		throttles.Set(IOThrottle{Device: "/dev/sda"}, []int{-1}, errNodeNotFound),
+		errNodeNotFound,
+	)
+
+	require.NoError(t, throttles.Set(IOThrottle{Device: "/dev/sda", ReadBps: 1}, nil, errNodeNotFound))
+	require.NoError(
+		t,
+		throttles.Set(IOThrottle{Device: "/dev/sdb", WriteIOps: 2}, []int{1}, errNodeNotFound),
+	)
+
+	var config container.HostConfig
+
+	Apply(*throttles.Node(0), &config)
+	Apply(*throttles.Node(1), &config)
+
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sda", Rate: 1}},
+		config.BlkioDeviceReadBps,
+	)
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sdb", Rate: 2}},
+		config.BlkioDeviceWriteIOps,
+	)
+	require.Empty(t, config.BlkioDeviceWriteBps)
+	require.Empty(t, config.BlkioDeviceReadIOps)
+}
+
+func TestCommands(t *testing.T) {
+	require.Equal(t, "/data/illusion-filler", FillCommand("/data")[4])
+	require.Equal(t, []string{"rm", "-f", "/data/illusion-filler"}, FreeCommand("/data"))
+}
@@ -0,0 +1,362 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+)
+
+const (
+	// Mount point of the volume shared by all nodes of the group. Contains WAL
+	// segments archived by each node in a subdirectory named as the hostname of
+	// the node and base backups in the backups subdirectory.
+	archiveDir        = "/var/lib/postgresql/archive"
+	archiveBackupsDir = archiveDir + "/backups"
+	archiveWALDir     = archiveDir + "/wal"
+
+	archiveVolumePrefix = "illusion-archive-"
+	archivePollInterval = 100 * time.Millisecond
+
+	recoveryTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+var (
+	ErrWALArchivingDisabled  = errors.New("WAL archiving is not enabled")
+	ErrRestorePointNameEmpty = errors.New("restore point name is empty")
+	ErrRecoveryTargetInvalid = errors.New("exactly one of the recovery target time and name must be specified")
+	ErrNodeNotRecovered      = errors.New("node was not recovered")
+	ErrBackupNotTaken        = errors.New("base backup was not taken")
+	ErrDriverEmpty           = errors.New("driver is empty")
+)
+
+// Base backup of the node taken by [Group.BaseBackup].
+type Backup struct {
+	// Name of the backup in the shared volume
+	Name string
+	// Index of the node from which the backup was taken
+	Node int
+}
+
+// Point up to which the WAL is replayed by [Group.Recover]. Exactly one of the
+// fields must be specified.
+type RecoveryTarget struct {
+	// Recovery stops at the first transaction committed after this time
+	Time time.Time
+	// Recovery stops at the restore point created by [Group.CreateRestorePoint]
+	Name string
+}
+
+func (target RecoveryTarget) validate() error {
+	if target.Time.IsZero() == (target.Name == "") {
+		return ErrRecoveryTargetInvalid
+	}
+
+	return nil
+}
+
+type recovery struct {
+	backup Backup
+	source string
+	target RecoveryTarget
+}
+
+// Enables WAL archiving (archive_mode) on nodes of the group. Completed WAL segments
+// are copied by the archive_command into a volume shared by all nodes of the
+// group. The volume also stores base backups taken by [Group.BaseBackup] and is
+// removed by the [Cleanup] function.
+func WithWALArchiving() Adjuster {
+	adj := func(opts *options) error {
+		opts.walArchiving = true
+		return nil
+	}
+
+	return adj
+}
+
+func (grp *Group) prepareArchiving() error {
+	if !grp.opts.walArchiving {
+		return nil
+	}
+
+	name, err := prepareHostname()
+	if err != nil {
+		return err
+	}
+
+	grp.archiveVolume = archiveVolumePrefix + name
+	grp.volumes = append(grp.volumes, grp.archiveVolume)
+
+	return nil
+}
+
+func (grp *Group) prepareNodeArchiving(req *testcontainers.GenericContainerRequest) {
+	if !grp.opts.walArchiving {
+		return
+	}
+
+	req.Mounts = append(
+		req.Mounts,
+		testcontainers.VolumeMount(grp.archiveVolume, archiveDir),
+	)
+}
+
+func (grp *Group) archivingSteps() []string {
+	if !grp.opts.walArchiving {
+		return nil
+	}
+
+	steps := []string{
+		"install -d -o postgres -g postgres -m 0700 " + archiveDir,
+	}
+
+	return steps
+}
+
+func (grp *Group) archivingSettings(node *node, settings map[string]string) {
+	if !grp.opts.walArchiving {
+		return
+	}
+
+	dir := archiveWALDir + "/" + node.hostname
+
+	settings["archive_mode"] = "on"
+	settings["archive_command"] = "mkdir -p " + dir +
+		" && test ! -f " + dir + "/%f && cp %p " + dir + "/%f"
+}
+
+func (grp *Group) recoverySteps(node *node) []string {
+	if node.recovery == nil {
+		return nil
+	}
+
+	backup := archiveBackupsDir + "/" + node.recovery.backup.Name
+
+	// Data directory is filled only once, so the recovered node can be restarted
+	steps := []string{
+		`if [ ! -s "$PGDATA/PG_VERSION" ]; then ` +
+			`cp -a ` + backup + `/. "$PGDATA" && touch "$PGDATA/recovery.signal"; fi`,
+	}
+
+	return steps
+}
+
+func (grp *Group) recoverySettings(node *node, settings map[string]string) {
+	if node.recovery == nil {
+		return
+	}
+
+	settings["restore_command"] = "cp " + archiveWALDir + "/" + node.recovery.source + "/%f %p"
+	settings["recovery_target_action"] = "promote"
+
+	if node.recovery.target.Name != "" {
+		settings["recovery_target_name"] = node.recovery.target.Name
+		return
+	}
+
+	settings["recovery_target_time"] = node.recovery.target.Time.UTC().Format(recoveryTimeLayout) + "+00"
+}
+
+// Takes a base backup of the node with the specified index using pg_basebackup and
+// stores it in the volume shared by the nodes of the group.
+func (grp *Group) BaseBackup(ctx context.Context, id int) (Backup, error) {
+	if !grp.opts.walArchiving {
+		return Backup{}, ErrWALArchivingDisabled
+	}
+
+	node, err := grp.node(id)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	name, err := prepareHostname()
+	if err != nil {
+		return Backup{}, err
+	}
+
+	cmd := []string{
+		"pg_basebackup",
+		"--pgdata", archiveBackupsDir + "/" + name,
+		"--checkpoint", "fast",
+		"--wal-method", "stream",
+		"--username", node.user,
+	}
+
+	if _, err := execute.Run(ctx, node.container, cmd, tcexec.WithUser("postgres")); err != nil {
+		return Backup{}, fmt.Errorf("%w: %w", ErrBackupNotTaken, err)
+	}
+
+	backup := Backup{
+		Name: name,
+		Node: id,
+	}
+
+	return backup, nil
+}
+
+// Creates the named restore point on the node with the specified index and waits
+// until the WAL segment containing it is archived.
+func (grp *Group) CreateRestorePoint(ctx context.Context, id int, name string) error {
+	if !grp.opts.walArchiving {
+		return ErrWALArchivingDisabled
+	}
+
+	if name == "" {
+		return ErrRestorePointNameEmpty
+	}
+
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	statement := "SELECT pg_create_restore_point(" + quoteLiteral(name) + ")"
+
+	if _, err := node.query(ctx, maintenanceDatabase, statement); err != nil {
+		return err
+	}
+
+	return grp.archiveWAL(ctx, node)
+}
+
+// Switches the node with the specified index to a new WAL segment and waits until
+// the completed segment is archived. Changes made on the node before the call
+// become available for recovery by [Group.Recover].
+func (grp *Group) ArchiveWAL(ctx context.Context, id int) error {
+	if !grp.opts.walArchiving {
+		return ErrWALArchivingDisabled
+	}
+
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	return grp.archiveWAL(ctx, node)
+}
+
+func (grp *Group) archiveWAL(ctx context.Context, node *node) error {
+	segment, err := node.query(ctx, maintenanceDatabase, "SELECT pg_walfile_name(pg_switch_wal())")
+	if err != nil {
+		return err
+	}
+
+	statement := "SELECT coalesce(last_archived_wal >= " + quoteLiteral(segment) +
+		", false) FROM pg_stat_archiver"
+
+	ticker := time.NewTicker(archivePollInterval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := node.query(ctx, maintenanceDatabase, statement)
+		if err != nil {
+			return err
+		}
+
+		if archived == "t" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Runs a new node from the base backup and replays the WAL archived by the node from
+// which the backup was taken up to the specified target. The recovered node is
+// promoted and added to the group. Returns index of the new node, whose DSN with
+// the specified driver as a scheme is returned by the [Group.DSNs] method.
+func (grp *Group) Recover(
+	ctx context.Context,
+	backup Backup,
+	target RecoveryTarget,
+	driver string,
+) (int, error) {
+	if !grp.opts.walArchiving {
+		return 0, ErrWALArchivingDisabled
+	}
+
+	if err := target.validate(); err != nil {
+		return 0, err
+	}
+
+	if strings.TrimSpace(driver) == "" {
+		return 0, ErrDriverEmpty
+	}
+
+	source, err := grp.node(backup.Node)
+	if err != nil {
+		return 0, err
+	}
+
+	hostname, err := prepareHostname()
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := &node{
+		database: source.database,
+		driver:   driver,
+		hostname: hostname,
+		imageTag: source.imageTag,
+		locale:   source.locale,
+		// Roles are recovered along with the data, so the password is the same as
+		// on the source node
+		password: source.password,
+		recovery: &recovery{
+			backup: backup,
+			source: source.hostname,
+			target: target,
+		},
+		user: source.user,
+	}
+
+	id := len(grp.nodes)
+
+	if err := grp.prepareNodeRequest(id, recovered); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNodeNotRecovered, err)
+	}
+
+	if err := grp.runRecoveredNode(ctx, recovered); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNodeNotRecovered, err)
+	}
+
+	return id, nil
+}
+
+func (grp *Group) runRecoveredNode(ctx context.Context, recovered *node) error {
+	created, err := testcontainers.GenericContainer(ctx, recovered.req)
+	if err != nil {
+		// Container can be created but not running, in which case an error will also
+		// be received. However, the container must be removed
+		return errors.Join(err, testcontainers.TerminateContainer(created))
+	}
+
+	host, err := created.Host(ctx)
+	if err != nil {
+		return errors.Join(err, testcontainers.TerminateContainer(created))
+	}
+
+	port, err := created.MappedPort(ctx, sqlPortTCP)
+	if err != nil {
+		return errors.Join(err, testcontainers.TerminateContainer(created))
+	}
+
+	recovered.container = created
+
+	grp.nodes = append(grp.nodes, recovered)
+	grp.drivers = append(grp.drivers, recovered.driver)
+	grp.dsns = append(grp.dsns, grp.prepareDSN(recovered, net.JoinHostPort(host, port.Port())))
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package psql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointInTimeRecovery(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"}, WithWALArchiving())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	db, err := sql.Open("pgx", grp.DSNs()[0].String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE history (value integer)")
+	require.NoError(t, err)
+
+	backup, err := grp.BaseBackup(t.Context(), 0)
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO history VALUES (1)")
+	require.NoError(t, err)
+
+	require.NoError(t, grp.CreateRestorePoint(t.Context(), 0, "first"))
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO history VALUES (2)")
+	require.NoError(t, err)
+
+	var moment time.Time
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT clock_timestamp()").Scan(&moment))
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO history VALUES (3)")
+	require.NoError(t, err)
+
+	require.NoError(t, grp.ArchiveWAL(t.Context(), 0))
+
+	targets := []struct {
+		target RecoveryTarget
+		sum    int
+	}{
+		{
+			target: RecoveryTarget{Name: "first"},
+			sum:    1,
+		},
+		{
+			target: RecoveryTarget{Time: moment},
+			sum:    3,
+		},
+	}
+
+	for _, target := range targets {
+		id, err := grp.Recover(t.Context(), backup, target.target, "pgx5")
+		require.NoError(t, err)
+
+		recovered, err := sql.Open("pgx", grp.DSNs()[id].String())
+		require.NoError(t, err)
+
+		var sum int
+
+		require.NoError(
+			t,
+			recovered.QueryRowContext(t.Context(), "SELECT sum(value) FROM history").Scan(&sum),
+		)
+		require.Equal(t, target.sum, sum)
+
+		_, err = recovered.ExecContext(t.Context(), "INSERT INTO history VALUES (4)")
+		require.NoError(t, err)
+
+		require.NoError(t, recovered.Close())
+	}
+}
+
+func TestRecoveryTargetValidate(t *testing.T) {
+	require.NoError(t, RecoveryTarget{Name: "point"}.validate())
+	require.NoError(t, RecoveryTarget{Time: time.Now()}.validate())
+	require.Error(t, RecoveryTarget{}.validate())
+	require.Error(t, RecoveryTarget{Name: "point", Time: time.Now()}.validate())
+}
+
+func TestArchivingDisabled(t *testing.T) {
+	grp := &Group{}
+
+	_, err := grp.BaseBackup(t.Context(), 0)
+	require.ErrorIs(t, err, ErrWALArchivingDisabled)
+
+	require.ErrorIs(t, grp.CreateRestorePoint(t.Context(), 0, "point"), ErrWALArchivingDisabled)
+	require.ErrorIs(t, grp.ArchiveWAL(t.Context(), 0), ErrWALArchivingDisabled)
+
+	_, err = grp.Recover(t.Context(), Backup{}, RecoveryTarget{Name: "point"}, "pgx5")
+	require.ErrorIs(t, err, ErrWALArchivingDisabled)
+}
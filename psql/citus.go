@@ -0,0 +1,184 @@
+package psql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+var (
+	ErrCitusNotInitialized = errors.New("citus cluster was not initialized")
+	ErrWorkersQuantityZero = errors.New("workers quantity is zero")
+)
+
+const (
+	citusImage = "citusdata/citus"
+
+	pgpassStagingFile = "/etc/postgresql/pgpass"
+	pgpassFile        = "/var/lib/postgresql/.pgpass"
+)
+
+// Runs Citus cluster from a coordinator and workers on the network of the group.
+// Node with the first driver is the coordinator, nodes with the rest drivers are
+// workers, so at least two drivers must be specified. Image citusdata/citus with
+// the specified tag is used.
+//
+// Workers are registered on the coordinator and the function waits until all of them
+// become active. Distributed tables are created in the database of the group, which
+// is specified in the returned DSNs.
+//
+// [Cleanup] function must be called when the cluster is no longer needed if
+// [RunCitus] did not return an error.
+func RunCitus(
+	ctx context.Context,
+	imageTag string,
+	drivers []string,
+	opts ...Adjuster,
+) ([]url.URL, Cleanup, error) {
+	grp, err := StartCitus(ctx, imageTag, drivers, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return grp.DSNs(), grp.Cleanup, nil
+}
+
+// Same as [RunCitus], but returns the group that provides operations on the running
+// nodes.
+//
+// [Group.Cleanup] method must be called when the cluster is no longer needed if
+// [StartCitus] did not return an error.
+func StartCitus(
+	ctx context.Context,
+	imageTag string,
+	drivers []string,
+	opts ...Adjuster,
+) (*Group, error) {
+	if len(drivers) < 2 { //nolint:mnd // Coordinator and at least one worker
+		return nil, ErrWorkersQuantityZero
+	}
+
+	grp := &Group{
+		drivers:  drivers,
+		imageTag: imageTag,
+		opts: options{
+			citus: true,
+			image: citusImage,
+		},
+	}
+
+	return grp.start(ctx, opts)
+}
+
+func (grp *Group) prepareNodeCitus(req *testcontainers.GenericContainerRequest) {
+	if !grp.opts.citus {
+		return
+	}
+
+	// Coordinator connects to workers with the credentials of the superuser, but
+	// passwords are different on each node
+	var pgpass strings.Builder
+
+	for _, node := range grp.nodes {
+		fields := []string{node.hostname, sqlPort, "*", node.user, node.password}
+
+		for id, field := range fields {
+			fields[id] = strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(field)
+		}
+
+		pgpass.WriteString(strings.Join(fields, ":") + "\n")
+	}
+
+	file := testcontainers.ContainerFile{
+		Reader:            bytes.NewReader([]byte(pgpass.String())),
+		ContainerFilePath: pgpassStagingFile,
+		FileMode:          certsFileMode,
+	}
+
+	req.Files = append(req.Files, file)
+	req.Env["PGPASSFILE"] = pgpassFile
+}
+
+func (grp *Group) citusSteps() []string {
+	if !grp.opts.citus {
+		return nil
+	}
+
+	steps := []string{
+		"install -o postgres -g postgres -m 0600 " + pgpassStagingFile + " " + pgpassFile,
+	}
+
+	return steps
+}
+
+func (grp *Group) citusSettings(settings map[string]string) {
+	if !grp.opts.citus {
+		return
+	}
+
+	settings["shared_preload_libraries"] = "citus"
+
+	if grp.tls != nil {
+		settings["citus.node_conninfo"] = prepareConnString(grp.tlsConnParams())
+	}
+}
+
+func (grp *Group) initializeCitus(ctx context.Context) error {
+	if !grp.opts.citus {
+		return nil
+	}
+
+	coordinator := grp.nodes[0]
+	workers := grp.nodes[1:]
+
+	statements := []string{
+		"SELECT citus_set_coordinator_host(" +
+			quoteLiteral(coordinator.hostname) + ", " + sqlPort + ")",
+	}
+
+	for _, worker := range workers {
+		statements = append(
+			statements,
+			"SELECT citus_add_node("+quoteLiteral(worker.hostname)+", "+sqlPort+")",
+		)
+	}
+
+	if _, err := coordinator.query(ctx, coordinator.database, statements...); err != nil {
+		return fmt.Errorf("%w: adding nodes: %w", ErrCitusNotInitialized, err)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: active workers: %w", ErrCitusNotInitialized, ctx.Err())
+		case <-ticker.C:
+			output, err := coordinator.query(
+				ctx,
+				coordinator.database,
+				"SELECT count(*) FROM citus_get_active_worker_nodes()",
+			)
+			if err != nil {
+				return fmt.Errorf("%w: active workers: %w", ErrCitusNotInitialized, err)
+			}
+
+			active, err := strconv.Atoi(output)
+			if err != nil {
+				return fmt.Errorf("%w: active workers: %w", ErrCitusNotInitialized, err)
+			}
+
+			if active == len(workers) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package psql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCitus(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunCitus(
+		t.Context(),
+		"13.0",
+		[]string{"pgx5", "pgx5", "pgx5"},
+		WithDatabase("app"),
+		WithClientCertAuth(),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	require.Len(t, dsns, 3)
+
+	coordinator := dsns[0]
+	coordinator.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", coordinator.String())
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE items (id bigint PRIMARY KEY)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "SELECT create_distributed_table('items', 'id')")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO items SELECT generate_series(1, 100)")
+	require.NoError(t, err)
+
+	var workers int
+
+	require.NoError(
+		t,
+		db.QueryRowContext(
+			t.Context(),
+			"SELECT count(DISTINCT nodename) FROM citus_shards WHERE table_name = 'items'::regclass",
+		).Scan(&workers),
+	)
+	require.Equal(t, 2, workers)
+
+	var count int
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT count(*) FROM items").Scan(&count))
+	require.Equal(t, 100, count)
+}
+
+func TestRunCitusWrongWorkersQuantity(t *testing.T) {
+	dsns, cleanup, err := RunCitus(t.Context(), "13.0", []string{"pgx5"})
+	require.ErrorIs(t, err, ErrWorkersQuantityZero)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+
+	dsns, cleanup, err = RunCitus(t.Context(), "13.0", nil)
+	require.ErrorIs(t, err, ErrWorkersQuantityZero)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+}
@@ -0,0 +1,72 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akramarenkov/illusion/internal/crash"
+
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	ErrDataOnTmpfs    = errors.New("data of the node is placed on tmpfs and would be lost")
+	ErrNodeNotKilled  = errors.New("node was not killed")
+	ErrNodeNotStarted = errors.New("node was not started")
+)
+
+// Kills all processes of the node with the SIGKILL signal, which simulates a crash
+// of the node, e.g. due to a power failure. Connections to the node are reset.
+//
+// Node can not be killed if its data is placed on tmpfs (see [WithTmpfs]), since
+// the data is lost when the node is stopped.
+func (grp *Group) Kill(ctx context.Context, id int) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, err)
+	}
+
+	if grp.opts.tmpfs {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, ErrDataOnTmpfs)
+	}
+
+	if err := crash.Kill(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotKilled, err)
+	}
+
+	return nil
+}
+
+// Starts the node killed by [Group.Kill] and waits until it recovers and accepts
+// connections.
+//
+// Port of the node can be published on another host port after the start, so the
+// DSNs of the group must be obtained again by the [Group.DSNs] method.
+func (grp *Group) Start(ctx context.Context, id int) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	if err := crash.Start(ctx, node.container.GetContainerID()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	waiting := wait.ForAll(grp.prepareSQLWaiting(node)).
+		WithStartupTimeoutDefault(grp.opts.startupTimeout).
+		WithDeadline(grp.opts.startupTimeout)
+
+	if err := waiting.WaitUntilReady(ctx, node.container); err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	dsn, err := grp.nodeDSN(ctx, node)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNodeNotStarted, err)
+	}
+
+	grp.dsns[id] = dsn
+
+	return nil
+}
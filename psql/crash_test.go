@@ -0,0 +1,73 @@
+package psql
+
+import (
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKill(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"})
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	db, err := openDSN(grp.DSNs()[0])
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE survived (id INT)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO survived VALUES (1)")
+	require.NoError(t, err)
+
+	require.NoError(t, grp.Kill(t.Context(), 0))
+	require.Error(t, db.PingContext(t.Context()))
+	require.NoError(t, db.Close())
+
+	require.NoError(t, grp.Start(t.Context(), 0))
+
+	db, err = openDSN(grp.DSNs()[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	var id int
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT id FROM survived").Scan(&id))
+	require.Equal(t, 1, id)
+
+	require.ErrorIs(t, grp.Kill(t.Context(), 1), ErrNodeNotFound)
+	require.ErrorIs(t, grp.Start(t.Context(), -1), ErrNodeNotFound)
+}
+
+func TestKillTmpfs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"}, WithTmpfs())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	require.ErrorIs(t, grp.Kill(t.Context(), 0), ErrDataOnTmpfs)
+}
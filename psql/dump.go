@@ -0,0 +1,318 @@
+package psql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/env"
+	"github.com/akramarenkov/illusion/internal/execute"
+)
+
+// Formats of the dump produced by pg_dump.
+type DumpFormat string
+
+const (
+	// Plain SQL script restored by psql
+	DumpPlain DumpFormat = "plain"
+	// Custom archive restored by pg_restore
+	DumpCustom DumpFormat = "custom"
+)
+
+const (
+	dumpDir = "/tmp"
+
+	// Signature at the beginning of archives in the custom format
+	customDumpSignature = "PGDMP"
+
+	goldenFileMode = 0o644
+	goldenDirMode  = 0o755
+)
+
+var (
+	ErrDumpFormatInvalid = errors.New("dump format is invalid")
+	ErrSchemaMismatch    = errors.New("schema snapshot does not match the golden file")
+)
+
+// Parameters of the dump.
+type DumpOpts struct {
+	// Database to dump. By default the database of the group is used
+	Database string
+	// By default [DumpPlain] is used
+	Format DumpFormat
+	// Dump only the object definitions, not data
+	SchemaOnly bool
+}
+
+func (opts DumpOpts) normalize(database string) DumpOpts {
+	if opts.Database == "" {
+		opts.Database = database
+	}
+
+	if opts.Format == "" {
+		opts.Format = DumpPlain
+	}
+
+	return opts
+}
+
+// Dumps the database of the node with the specified index by pg_dump run inside the
+// node container and writes the dump to the specified writer. If dumping fails,
+// part of the dump can already be written to the writer.
+func (grp *Group) Dump(ctx context.Context, id int, opts DumpOpts, dst io.Writer) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	opts = opts.normalize(node.database)
+
+	if opts.Format != DumpPlain && opts.Format != DumpCustom {
+		return fmt.Errorf("%w: %s", ErrDumpFormatInvalid, opts.Format)
+	}
+
+	cmd := []string{
+		"pg_dump",
+		"--username", node.user,
+		"--format", string(opts.Format),
+	}
+
+	if opts.SchemaOnly {
+		cmd = append(cmd, "--schema-only")
+	}
+
+	cmd = append(cmd, opts.Database)
+
+	return node.dump(ctx, cmd, dst)
+}
+
+// Restores the dump produced by [Group.Dump] into the specified database of the
+// node with the specified index. The database is created if it does not exist.
+// If database is not specified, the database of the group is used. Format of the
+// dump is detected automatically.
+func (grp *Group) Restore(ctx context.Context, id int, database string, src io.Reader) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	if database == "" {
+		database = node.database
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	if err := node.createDatabase(ctx, database); err != nil {
+		return err
+	}
+
+	file, err := prepareDumpFile()
+	if err != nil {
+		return err
+	}
+
+	if err := node.container.CopyToContainer(ctx, data, file, certsFileMode); err != nil {
+		return err
+	}
+
+	defer node.removeFile(ctx, file)
+
+	cmd := []string{
+		"psql",
+		"--no-psqlrc",
+		"--quiet",
+		"--set", "ON_ERROR_STOP=1",
+		"--username", node.user,
+		"--dbname", database,
+		"--file", file,
+	}
+
+	if bytes.HasPrefix(data, []byte(customDumpSignature)) {
+		cmd = []string{
+			"pg_restore",
+			"--exit-on-error",
+			"--username", node.user,
+			"--dbname", database,
+			file,
+		}
+	}
+
+	if _, err := execute.Run(ctx, node.container, cmd); err != nil {
+		return fmt.Errorf("restoring: %w", err)
+	}
+
+	return nil
+}
+
+// Returns normalized schema of the database of the node with the specified index.
+// If database is not specified, the database of the group is used.
+//
+// Schema is dumped by pg_dump without owners and privileges, after which comments,
+// settings and empty lines, which can differ between runs and versions of
+// pg_dump, are removed. Snapshots of the databases with the same schema are
+// equal, so the result of migrations can be compared with a reference.
+func (grp *Group) SchemaSnapshot(ctx context.Context, id int, database string) ([]byte, error) {
+	node, err := grp.node(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if database == "" {
+		database = node.database
+	}
+
+	cmd := []string{
+		"pg_dump",
+		"--username", node.user,
+		"--schema-only",
+		"--no-owner",
+		"--no-privileges",
+		database,
+	}
+
+	var dump bytes.Buffer
+
+	if err := node.dump(ctx, cmd, &dump); err != nil {
+		return nil, err
+	}
+
+	return normalizeSchema(dump.Bytes())
+}
+
+// Compares schema snapshot produced by [Group.SchemaSnapshot] with the contents of
+// the golden file. Returns an error that contains the first differing line if they
+// do not match.
+//
+// If the ILLUSION_UPDATE_GOLDEN environment variable is set to a non-empty value,
+// the golden file is overwritten with the snapshot instead.
+func CompareSchemaSnapshot(snapshot []byte, golden string) error {
+	if os.Getenv(env.UpdateGolden) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), goldenDirMode); err != nil {
+			return err
+		}
+
+		return os.WriteFile(golden, snapshot, goldenFileMode)
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		return err
+	}
+
+	return compareSchemas(expected, snapshot)
+}
+
+func compareSchemas(expected []byte, actual []byte) error {
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+
+	for id := range max(len(expectedLines), len(actualLines)) {
+		var want, got string
+
+		if id < len(expectedLines) {
+			want = expectedLines[id]
+		}
+
+		if id < len(actualLines) {
+			got = actualLines[id]
+		}
+
+		if want != got {
+			return fmt.Errorf(
+				"%w: line %d: expected %q, actual %q",
+				ErrSchemaMismatch,
+				id+1,
+				want,
+				got,
+			)
+		}
+	}
+
+	return ErrSchemaMismatch
+}
+
+func normalizeSchema(dump []byte) ([]byte, error) {
+	var normalized bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(nil, len(dump)+1)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "--"):
+		case strings.HasPrefix(line, "SET "):
+		case strings.HasPrefix(line, "SELECT pg_catalog.set_config("):
+		// Meta-commands with random keys added by recent versions of pg_dump
+		case strings.HasPrefix(line, `\restrict `), strings.HasPrefix(line, `\unrestrict `):
+		default:
+			normalized.WriteString(line)
+			normalized.WriteByte('\n')
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return normalized.Bytes(), nil
+}
+
+// Runs pg_dump with the output written to a temporary file in the container and
+// copies the file into the writer.
+// Output of pg_dump is streamed to the writer instead of being written to a file in
+// the container, which would double the disk usage, e.g. on tmpfs.
+func (n *node) dump(ctx context.Context, cmd []string, dst io.Writer) error {
+	if err := execute.Stream(ctx, n.container.GetContainerID(), cmd, dst); err != nil {
+		return fmt.Errorf("dumping: %w", err)
+	}
+
+	return nil
+}
+
+func (n *node) createDatabase(ctx context.Context, database string) error {
+	statement := "SELECT count(*) FROM pg_database WHERE datname = " + quoteLiteral(database)
+
+	count, err := n.query(ctx, maintenanceDatabase, statement)
+	if err != nil {
+		return err
+	}
+
+	if count != "0" {
+		return nil
+	}
+
+	_, err = n.query(ctx, maintenanceDatabase, "CREATE DATABASE "+quoteIdentifier(database))
+
+	return err
+}
+
+// Temporary files are removed on a best-effort basis, because they are removed
+// anyway along with the container.
+func (n *node) removeFile(ctx context.Context, file string) {
+	_, _ = execute.Run(ctx, n.container, []string{"rm", "-f", file})
+}
+
+func prepareDumpFile() (string, error) {
+	name, err := prepareHostname()
+	if err != nil {
+		return "", err
+	}
+
+	return dumpDir + "/illusion-" + name + ".dump", nil
+}
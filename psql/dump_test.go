@@ -0,0 +1,160 @@
+package psql
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/env"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpRestore(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5", "pgx5"})
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dsn := grp.DSNs()[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(
+		t.Context(),
+		"CREATE TABLE accounts (id integer PRIMARY KEY, balance integer NOT NULL)",
+	)
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "CREATE INDEX accounts_balance ON accounts (balance)")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(t.Context(), "INSERT INTO accounts VALUES (1, 100), (2, 200)")
+	require.NoError(t, err)
+
+	for _, format := range []DumpFormat{DumpPlain, DumpCustom} {
+		var dump bytes.Buffer
+
+		require.NoError(t, grp.Dump(t.Context(), 0, DumpOpts{Format: format}, &dump))
+		require.NotZero(t, dump.Len())
+
+		database := "restored_" + string(format)
+
+		require.NoError(t, grp.Restore(t.Context(), 1, database, &dump))
+
+		restored, err := query(
+			t.Context(),
+			grp.nodes[1].container,
+			grp.nodes[1].user,
+			database,
+			"SELECT sum(balance) FROM accounts",
+		)
+		require.NoError(t, err)
+		require.Equal(t, "300", restored)
+	}
+
+	var schema bytes.Buffer
+
+	require.NoError(t, grp.Dump(t.Context(), 0, DumpOpts{SchemaOnly: true}, &schema))
+	require.NoError(t, grp.Restore(t.Context(), 1, "", &schema))
+
+	expected, err := grp.SchemaSnapshot(t.Context(), 0, "")
+	require.NoError(t, err)
+	require.Contains(t, string(expected), "CREATE TABLE public.accounts")
+
+	actual, err := grp.SchemaSnapshot(t.Context(), 1, "")
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	golden := filepath.Join(t.TempDir(), "schema.sql")
+
+	t.Setenv(env.UpdateGolden, "true")
+	require.NoError(t, CompareSchemaSnapshot(expected, golden))
+
+	t.Setenv(env.UpdateGolden, "")
+	require.NoError(t, CompareSchemaSnapshot(actual, golden))
+
+	_, err = db.ExecContext(t.Context(), "ALTER TABLE accounts ADD COLUMN owner text")
+	require.NoError(t, err)
+
+	changed, err := grp.SchemaSnapshot(t.Context(), 0, "")
+	require.NoError(t, err)
+	require.ErrorIs(t, CompareSchemaSnapshot(changed, golden), ErrSchemaMismatch)
+
+	require.ErrorIs(
+		t,
+		grp.Dump(t.Context(), 0, DumpOpts{Format: "tar"}, &schema),
+		ErrDumpFormatInvalid,
+	)
+}
+
+func TestNormalizeSchema(t *testing.T) {
+	dump := "--\n" +
+		"-- PostgreSQL database dump\n" +
+		"--\n" +
+		"\\restrict abcdef\n" +
+		"\n" +
+		"SET statement_timeout = 0;\n" +
+		"SELECT pg_catalog.set_config('search_path', '', false);\n" +
+		"\n" +
+		"CREATE TABLE public.accounts (\n" +
+		"    id integer NOT NULL   \n" +
+		");\n" +
+		"\n" +
+		"\\unrestrict abcdef\n"
+
+	normalized, err := normalizeSchema([]byte(dump))
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"CREATE TABLE public.accounts (\n"+
+			"    id integer NOT NULL\n"+
+			");\n",
+		string(normalized),
+	)
+}
+
+func TestCompareSchemaSnapshot(t *testing.T) {
+	t.Setenv(env.UpdateGolden, "")
+
+	golden := filepath.Join(t.TempDir(), "schema.sql")
+
+	require.NoError(t, os.WriteFile(golden, []byte("CREATE TABLE a;\nCREATE TABLE b;\n"), 0o600))
+
+	require.NoError(t, CompareSchemaSnapshot([]byte("CREATE TABLE a;\nCREATE TABLE b;\n"), golden))
+
+	err := CompareSchemaSnapshot([]byte("CREATE TABLE a;\nCREATE TABLE c;\n"), golden)
+	require.ErrorIs(t, err, ErrSchemaMismatch)
+	require.ErrorContains(t, err, "line 2")
+
+	err = CompareSchemaSnapshot([]byte("CREATE TABLE a;\n"), golden)
+	require.ErrorIs(t, err, ErrSchemaMismatch)
+
+	require.Error(t, CompareSchemaSnapshot(nil, filepath.Join(t.TempDir(), "absent.sql")))
+
+	updated := filepath.Join(t.TempDir(), "golden", "schema.sql")
+
+	t.Setenv(env.UpdateGolden, "true")
+	require.NoError(t, CompareSchemaSnapshot([]byte("CREATE TABLE c;\n"), updated))
+
+	t.Setenv(env.UpdateGolden, "")
+	require.NoError(t, CompareSchemaSnapshot([]byte("CREATE TABLE c;\n"), updated))
+}
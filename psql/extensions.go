@@ -0,0 +1,197 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	ErrExtensionNameEmpty = errors.New("extension name is empty")
+	ErrImageConflict      = errors.New("different images are selected")
+	ErrImageEmpty         = errors.New("image is empty")
+	ErrLibraryNameEmpty   = errors.New("library name is empty")
+)
+
+// Extension created in the database of the group on each node.
+type Extension struct {
+	// Name of the extension. Required parameter
+	Name string
+
+	// SQL statement that is executed after the extension is created to confirm that
+	// it is usable. Node is not considered ready until the statement is executed
+	// successfully. Optional parameter
+	Probe string
+}
+
+// Sets the image repository used instead of postgres, e.g. pgvector/pgvector. The
+// image must be based on the official postgres image. Tag of the image is specified
+// when running the group.
+func WithImage(repository string) Adjuster {
+	adj := func(opts *options) error {
+		if repository == "" {
+			return ErrImageEmpty
+		}
+
+		if opts.image != "" && opts.image != repository {
+			return fmt.Errorf("%w: %s, %s", ErrImageConflict, opts.image, repository)
+		}
+
+		opts.image = repository
+
+		return nil
+	}
+
+	return adj
+}
+
+// Adds libraries to the shared_preload_libraries parameter.
+func WithSharedPreloadLibraries(libraries ...string) Adjuster {
+	adj := func(opts *options) error {
+		for _, library := range libraries {
+			if library == "" {
+				return ErrLibraryNameEmpty
+			}
+
+			if !slices.Contains(opts.libraries, library) {
+				opts.libraries = append(opts.libraries, library)
+			}
+		}
+
+		return nil
+	}
+
+	return adj
+}
+
+// Creates extensions in the database of the group on each node. Extensions are
+// created with the CASCADE option in the order of specification.
+func WithExtensions(extensions ...Extension) Adjuster {
+	adj := func(opts *options) error {
+		for _, extension := range extensions {
+			if extension.Name == "" {
+				return ErrExtensionNameEmpty
+			}
+		}
+
+		opts.extensions = append(opts.extensions, extensions...)
+
+		return nil
+	}
+
+	return adj
+}
+
+// Selects pgvector/pgvector image and creates the vector extension. Tag of the
+// image must be specified in the form of the pgvector/pgvector image, e.g. pg17.
+func WithPgvector() Adjuster {
+	return combine(
+		WithImage("pgvector/pgvector"),
+		WithExtensions(
+			Extension{
+				Name:  "vector",
+				Probe: "SELECT '[1,2,3]'::vector <-> '[3,2,1]'::vector",
+			},
+		),
+	)
+}
+
+// Selects postgis/postgis image and creates the postgis extension. Tag of the image
+// must be specified in the form of the postgis/postgis image, e.g. 17-3.5.
+func WithPostGIS() Adjuster {
+	return combine(
+		WithImage("postgis/postgis"),
+		WithExtensions(
+			Extension{
+				Name:  "postgis",
+				Probe: "SELECT ST_AsText(ST_MakePoint(1, 2))",
+			},
+		),
+	)
+}
+
+// Selects timescale/timescaledb image, adds timescaledb to the
+// shared_preload_libraries parameter and creates the timescaledb extension. Tag of
+// the image must be specified in the form of the timescale/timescaledb image, e.g.
+// latest-pg17.
+func WithTimescaleDB() Adjuster {
+	return combine(
+		WithImage("timescale/timescaledb"),
+		WithSharedPreloadLibraries("timescaledb"),
+		WithExtensions(
+			Extension{
+				Name: "timescaledb",
+				Probe: "CREATE TEMPORARY TABLE illusion_probe (time timestamptz NOT NULL); " +
+					"SELECT create_hypertable('illusion_probe', 'time')",
+			},
+		),
+	)
+}
+
+// Adds pg_stat_statements to the shared_preload_libraries parameter and creates the
+// pg_stat_statements extension. Can be used with any image.
+func WithPgStatStatements() Adjuster {
+	return combine(
+		WithSharedPreloadLibraries("pg_stat_statements"),
+		WithExtensions(
+			Extension{
+				Name:  "pg_stat_statements",
+				Probe: "SELECT count(*) FROM pg_stat_statements",
+			},
+		),
+	)
+}
+
+func combine(adjusters ...Adjuster) Adjuster {
+	adj := func(opts *options) error {
+		for _, adj := range adjusters {
+			if err := adj(opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return adj
+}
+
+func (grp *Group) extensionsSettings(settings map[string]string) {
+	if len(grp.opts.libraries) == 0 {
+		return
+	}
+
+	libraries := grp.opts.libraries
+
+	if current, exists := settings["shared_preload_libraries"]; exists {
+		libraries = slices.Concat(strings.Split(current, ","), libraries)
+	}
+
+	settings["shared_preload_libraries"] = strings.Join(libraries, ",")
+}
+
+func (n *node) createExtensions(extensions []Extension) waitFunc {
+	create := func(ctx context.Context, target wait.StrategyTarget) error {
+		for _, extension := range extensions {
+			statements := []string{
+				"CREATE EXTENSION IF NOT EXISTS " + quoteIdentifier(extension.Name) + " CASCADE",
+			}
+
+			if extension.Probe != "" {
+				statements = append(statements, extension.Probe)
+			}
+
+			if _, err := query(ctx, target, n.user, n.database, statements...); err != nil {
+				return fmt.Errorf("creating extension %s: %w", extension.Name, err)
+			}
+		}
+
+		return nil
+	}
+
+	return create
+}
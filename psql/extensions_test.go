@@ -0,0 +1,103 @@
+package psql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExtensions(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	presets := []struct {
+		imageTag  string
+		adjusters []Adjuster
+		extension string
+	}{
+		{
+			imageTag:  "pg17",
+			adjusters: []Adjuster{WithPgvector(), WithPgStatStatements()},
+			extension: "vector",
+		},
+		{
+			imageTag:  "17-3.5",
+			adjusters: []Adjuster{WithPostGIS()},
+			extension: "postgis",
+		},
+		{
+			imageTag:  "latest-pg17",
+			adjusters: []Adjuster{WithTimescaleDB(), WithPgStatStatements()},
+			extension: "timescaledb",
+		},
+	}
+
+	for _, preset := range presets {
+		dsns, cleanup, err := RunWith(
+			t.Context(),
+			preset.imageTag,
+			[]string{"pgx5"},
+			append(preset.adjusters, WithDatabase("app"))...,
+		)
+		require.NoError(t, err)
+
+		dsn := dsns[0]
+		dsn.Scheme = "postgres"
+
+		db, err := sql.Open("pgx", dsn.String())
+		require.NoError(t, err)
+
+		var exists bool
+
+		require.NoError(
+			t,
+			db.QueryRowContext(
+				t.Context(),
+				"SELECT EXISTS (SELECT FROM pg_extension WHERE extname = $1)",
+				preset.extension,
+			).Scan(&exists),
+		)
+		require.True(t, exists)
+
+		require.NoError(t, db.Close())
+		require.NoError(t, cleanup(t.Context(), t))
+	}
+}
+
+func TestWithImage(t *testing.T) {
+	var opts options
+
+	require.NoError(t, WithPgvector()(&opts))
+	require.ErrorIs(t, WithPostGIS()(&opts), ErrImageConflict)
+	require.ErrorIs(t, WithImage("")(&opts), ErrImageEmpty)
+	require.NoError(t, WithImage("pgvector/pgvector")(&opts))
+}
+
+func TestExtensionsSettings(t *testing.T) {
+	grp := &Group{
+		opts: options{
+			citus: true,
+		},
+	}
+
+	require.NoError(t, WithTimescaleDB()(&grp.opts))
+	require.NoError(t, WithPgStatStatements()(&grp.opts))
+	require.NoError(t, WithSharedPreloadLibraries("timescaledb")(&grp.opts))
+
+	require.ErrorIs(t, WithSharedPreloadLibraries("")(&grp.opts), ErrLibraryNameEmpty)
+	require.ErrorIs(t, WithExtensions(Extension{})(&grp.opts), ErrExtensionNameEmpty)
+
+	settings := grp.prepareSettings(&node{})
+
+	require.Equal(t,
+		"citus,timescaledb,pg_stat_statements",
+		settings["shared_preload_libraries"],
+	)
+}
@@ -0,0 +1,105 @@
+package psql
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/akramarenkov/illusion/internal/keep"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+)
+
+const (
+	keepKind = "psql"
+)
+
+// Subset of the [testing.TB] interface.
+type TB interface {
+	Failed() bool
+	Logf(format string, args ...any)
+}
+
+// Removes all groups kept on failure of the tests, e.g. after debugging or before
+// the next run of the tests. Removed are containers, networks and volumes of the
+// groups, but not the files generated on the host.
+func RemoveKept(ctx context.Context) error {
+	return keep.Remove(ctx, keepKind)
+}
+
+// Returns the failed test for which the group should be kept.
+func (grp *Group) failedTest(tests []TB) TB {
+	if !grp.keepOnFailure {
+		return nil
+	}
+
+	for _, t := range tests {
+		if t != nil && t.Failed() {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (grp *Group) networkCustomizers() []network.NetworkCustomizer {
+	if !grp.keepOnFailure {
+		return nil
+	}
+
+	return []network.NetworkCustomizer{network.WithLabels(keep.NetworkLabels(keepKind))}
+}
+
+func (grp *Group) prepareKeep(req *testcontainers.GenericContainerRequest) {
+	if !grp.keepOnFailure {
+		return
+	}
+
+	keep.PrepareRequest(keepKind, req)
+}
+
+// Logs the description of the kept group and detaches the group from its
+// resources, so they are not removed by the subsequent calls of the cleanup.
+func (grp *Group) keep(ctx context.Context, t TB) error {
+	containers := make([]testcontainers.Container, 0, len(grp.nodes)+len(grp.bouncers))
+
+	for _, node := range grp.nodes {
+		containers = append(containers, node.container)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(grp.bouncers)) {
+		containers = append(containers, grp.bouncers[id].container)
+	}
+
+	environ := keep.Environment{
+		DSNs:       grp.DSNs(),
+		Containers: keep.Names(ctx, containers...),
+		Volumes:    grp.volumes,
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(grp.pooledDSNs)) {
+		environ.DSNs = append(environ.DSNs, grp.pooledDSNs[id])
+	}
+
+	if grp.network != nil {
+		environ.Network = grp.network.Name
+	}
+
+	if grp.tls != nil {
+		environ.Dirs = []string{grp.tls.dir}
+	}
+
+	t.Logf("postgres group: %s", environ)
+
+	grp.bouncers = nil
+	grp.nodes = nil
+	grp.network = nil
+	grp.tls = nil
+	grp.volumes = nil
+
+	// Subsequent calls of the cleanup have nothing to keep or remove
+	grp.keepOnFailure = false
+
+	return grp.nodeLogs.Close()
+}
@@ -0,0 +1,114 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/env"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+	"github.com/akramarenkov/illusion/internal/keep"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testTB struct {
+	failed bool
+	logged []string
+}
+
+func (tb *testTB) Failed() bool {
+	return tb.failed
+}
+
+func (tb *testTB) Logf(format string, args ...any) {
+	tb.logged = append(tb.logged, fmt.Sprintf(format, args...))
+}
+
+func TestKeepOnFailure(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	t.Setenv(env.Keep, keep.OnFailure)
+
+	tb := &testTB{}
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"}, WithWALArchiving())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, RemoveKept(context.WithoutCancel(t.Context())))
+	}()
+
+	db, err := openDSN(grp.DSNs()[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	ping := func() error {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		return db.PingContext(ctx)
+	}
+
+	tb.failed = true
+
+	require.NoError(t, grp.Cleanup(t.Context(), tb))
+	require.NoError(t, grp.Cleanup(t.Context(), tb))
+	require.Len(t, tb.logged, 1)
+	require.Contains(t, tb.logged[0], grp.DSNs()[0].String())
+	require.Contains(t, tb.logged[0], "remove with: docker rm -f -v ")
+	require.Contains(t, tb.logged[0], "docker volume rm "+archiveVolumePrefix)
+	require.NoError(t, ping())
+
+	require.NoError(t, RemoveKept(t.Context()))
+	require.Error(t, ping())
+}
+
+func TestKeepOnFailurePassed(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	t.Setenv(env.Keep, keep.OnFailure)
+
+	tb := &testTB{}
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"})
+	require.NoError(t, err)
+
+	require.NoError(t, grp.Cleanup(t.Context(), tb))
+	require.Empty(t, tb.logged)
+}
+
+func TestKeepOnFailureFailedTest(t *testing.T) {
+	failed := &testTB{failed: true}
+	passed := &testTB{}
+
+	t.Setenv(env.Keep, "")
+
+	grp := &Group{keepOnFailure: keep.Enabled()}
+
+	require.Nil(t, grp.failedTest([]TB{failed}))
+	require.Nil(t, grp.networkCustomizers())
+
+	t.Setenv(env.Keep, keep.OnFailure)
+
+	grp = &Group{keepOnFailure: keep.Enabled()}
+
+	require.Nil(t, grp.failedTest(nil))
+	require.Nil(t, grp.failedTest([]TB{nil, passed}))
+	require.Same(t, failed, grp.failedTest([]TB{passed, failed}))
+	require.Len(t, grp.networkCustomizers(), 1)
+}
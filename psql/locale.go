@@ -0,0 +1,215 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Names of the locale providers reported by [Group.LocaleSettings].
+const (
+	LocaleProviderBuiltin = "builtin"
+	LocaleProviderICU     = "icu"
+	LocaleProviderLibc    = "libc"
+)
+
+var ErrLocaleSettingsNotRecognized = errors.New("locale settings are not recognized")
+
+// Timezone and locale configuration of the node.
+//
+// Libc locales that are not present in the image, e.g. de_DE.UTF-8, are generated
+// by localedef at the start of the container, which is supported by the Debian
+// based images.
+type Locale struct {
+	// Timezone of the container (TZ environment variable). Also used by initdb as a
+	// default of the server timezone and log_timezone settings
+	TZ string
+	// LANG environment variable of the container
+	Lang string
+	// Collation order of the databases (initdb --lc-collate)
+	Collate string
+	// Character classification of the databases (initdb --lc-ctype)
+	CType string
+	// Enables ICU locale provider with the specified ICU locale, e.g. de-DE
+	// (initdb --locale-provider=icu --icu-locale)
+	ICULocale string
+	// Timezone of the server (timezone setting)
+	Timezone string
+}
+
+// Effective timezone and locale settings of the node database.
+type LocaleSettings struct {
+	Collate        string
+	CType          string
+	LocaleProvider string
+	// Empty if the locale provider is libc
+	Locale      string
+	LogTimezone string
+	Timezone    string
+}
+
+// Sets timezone and locale configuration of the nodes with the specified indices.
+// If indices are not specified, configuration is used for all nodes for which it is
+// not specified individually, so nodes with different configurations can be run in
+// one group.
+func WithLocale(locale Locale, nodes ...int) Adjuster {
+	adj := func(opts *options) error {
+		if len(nodes) == 0 {
+			opts.locale = locale
+			return nil
+		}
+
+		if opts.nodeLocales == nil {
+			opts.nodeLocales = make(map[int]Locale, len(nodes))
+		}
+
+		for _, id := range nodes {
+			if id < 0 {
+				return fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+			}
+
+			opts.nodeLocales[id] = locale
+		}
+
+		return nil
+	}
+
+	return adj
+}
+
+func (opts options) nodeLocale(id int) Locale {
+	if locale, exists := opts.nodeLocales[id]; exists {
+		return locale
+	}
+
+	return opts.locale
+}
+
+func (grp *Group) validateLocales() error {
+	for id := range grp.opts.nodeLocales {
+		if id >= len(grp.drivers) {
+			return fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+		}
+	}
+
+	return nil
+}
+
+func (locale Locale) env(env map[string]string) {
+	if locale.TZ != "" {
+		env["TZ"] = locale.TZ
+	}
+
+	if locale.Lang != "" {
+		env["LANG"] = locale.Lang
+	}
+}
+
+func (locale Locale) initdbArgs() []string {
+	args := make([]string, 0)
+
+	if locale.Collate != "" {
+		args = append(args, "--lc-collate="+locale.Collate)
+	}
+
+	if locale.CType != "" {
+		args = append(args, "--lc-ctype="+locale.CType)
+	}
+
+	if locale.ICULocale != "" {
+		args = append(args, "--locale-provider=icu", "--icu-locale="+locale.ICULocale)
+	}
+
+	return args
+}
+
+func (locale Locale) settings(settings map[string]string) {
+	if locale.Timezone != "" {
+		settings["timezone"] = locale.Timezone
+	}
+}
+
+// Generates libc locales that are not built into the image.
+func (locale Locale) steps() []string {
+	steps := make([]string, 0)
+	generated := make(map[string]bool)
+
+	for _, name := range []string{locale.Lang, locale.Collate, locale.CType} {
+		if generated[name] {
+			continue
+		}
+
+		generated[name] = true
+
+		// C.UTF-8 is built into the C library
+		definition, charset, found := strings.Cut(name, ".")
+		if !found || definition == "" || definition == "C" || charset == "" {
+			continue
+		}
+
+		steps = append(
+			steps,
+			"localedef -i "+quoteShell(definition)+
+				" -c -f "+quoteShell(charset)+" "+quoteShell(name),
+		)
+	}
+
+	return steps
+}
+
+// Returns additional arguments of initdb specified for the node.
+func (grp *Group) nodeInitdbArgs(node *node) string {
+	args := append([]string{grp.opts.initdbArgs}, node.locale.initdbArgs()...)
+
+	return strings.TrimSpace(strings.Join(args, " "))
+}
+
+// Returns effective timezone and locale settings of the database of the node with
+// the specified index.
+func (grp *Group) LocaleSettings(ctx context.Context, id int) (LocaleSettings, error) {
+	node, err := grp.node(id)
+	if err != nil {
+		return LocaleSettings{}, err
+	}
+
+	// Column with the ICU locale is named differently in different versions
+	statement := "SELECT concat_ws('|', datcollate, datctype, datlocprovider, " +
+		"coalesce(to_jsonb(db) ->> 'datlocale', to_jsonb(db) ->> 'daticulocale', ''), " +
+		"current_setting('log_timezone'), current_setting('TimeZone')) " +
+		"FROM pg_database AS db WHERE datname = current_database()"
+
+	output, err := node.query(ctx, node.database, statement)
+	if err != nil {
+		return LocaleSettings{}, err
+	}
+
+	fields := strings.Split(output, "|")
+	if len(fields) != 6 { //nolint:mnd // Number of selected values
+		return LocaleSettings{}, fmt.Errorf("%w: %s", ErrLocaleSettingsNotRecognized, output)
+	}
+
+	settings := LocaleSettings{
+		Collate:        fields[0],
+		CType:          fields[1],
+		LocaleProvider: localeProviderName(fields[2]),
+		Locale:         fields[3],
+		LogTimezone:    fields[4],
+		Timezone:       fields[5],
+	}
+
+	return settings, nil
+}
+
+func localeProviderName(code string) string {
+	switch code {
+	case "b":
+		return LocaleProviderBuiltin
+	case "i":
+		return LocaleProviderICU
+	case "c":
+		return LocaleProviderLibc
+	}
+
+	return code
+}
@@ -0,0 +1,135 @@
+package psql
+
+import (
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLocale(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	libc := Locale{
+		TZ:       "Europe/Berlin",
+		Lang:     "de_DE.UTF-8",
+		Collate:  "C",
+		CType:    "de_DE.UTF-8",
+		Timezone: "Asia/Tokyo",
+	}
+
+	icu := Locale{
+		ICULocale: "en-US",
+	}
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithLocale(libc),
+		WithLocale(icu, 1),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	settings, err := grp.LocaleSettings(t.Context(), 0)
+	require.NoError(t, err)
+	require.Equal(t, "C", settings.Collate)
+	require.Equal(t, "de_DE.UTF-8", settings.CType)
+	require.Equal(t, LocaleProviderLibc, settings.LocaleProvider)
+	require.Empty(t, settings.Locale)
+	require.Equal(t, "Europe/Berlin", settings.LogTimezone)
+	require.Equal(t, "Asia/Tokyo", settings.Timezone)
+
+	settings, err = grp.LocaleSettings(t.Context(), 1)
+	require.NoError(t, err)
+	require.Equal(t, LocaleProviderICU, settings.LocaleProvider)
+	require.Equal(t, "en-US", settings.Locale)
+	require.Equal(t, "Etc/UTC", settings.Timezone)
+
+	statement := "SELECT string_agg(value, '' ORDER BY value) FROM (VALUES ('a'), ('B')) AS t(value)"
+
+	sorted, err := grp.nodes[0].query(t.Context(), grp.nodes[0].database, statement)
+	require.NoError(t, err)
+	require.Equal(t, "Ba", sorted)
+
+	sorted, err = grp.nodes[1].query(t.Context(), grp.nodes[1].database, statement)
+	require.NoError(t, err)
+	require.Equal(t, "aB", sorted)
+}
+
+func TestWithLocale(t *testing.T) {
+	opts := options{}
+
+	common := Locale{TZ: "UTC"}
+	individual := Locale{ICULocale: "de-DE"}
+
+	require.NoError(t, WithLocale(common)(&opts))
+	require.NoError(t, WithLocale(individual, 1, 2)(&opts))
+	require.Error(t, WithLocale(individual, -1)(&opts))
+
+	require.Equal(t, common, opts.nodeLocale(0))
+	require.Equal(t, individual, opts.nodeLocale(1))
+	require.Equal(t, individual, opts.nodeLocale(2))
+	require.Equal(t, common, opts.nodeLocale(3))
+
+	grp := &Group{
+		drivers: []string{"pgx5", "pgx5"},
+		opts:    opts,
+	}
+
+	require.ErrorIs(t, grp.validateLocales(), ErrNodeNotFound)
+}
+
+func TestLocaleInitdbArgs(t *testing.T) {
+	grp := &Group{
+		opts: options{
+			initdbArgs: "--data-checksums",
+		},
+	}
+
+	node := &node{
+		locale: Locale{
+			Collate:   "C",
+			CType:     "de_DE.UTF-8",
+			ICULocale: "de-DE",
+		},
+	}
+
+	require.Equal(
+		t,
+		"--data-checksums --lc-collate=C --lc-ctype=de_DE.UTF-8 "+
+			"--locale-provider=icu --icu-locale=de-DE",
+		grp.nodeInitdbArgs(node),
+	)
+
+	grp.opts.initdbArgs = ""
+	node.locale = Locale{}
+
+	require.Empty(t, grp.nodeInitdbArgs(node))
+}
+
+func TestLocaleSteps(t *testing.T) {
+	locale := Locale{
+		Lang:    "de_DE.UTF-8",
+		Collate: "C.UTF-8",
+		CType:   "de_DE.UTF-8",
+	}
+
+	require.Equal(
+		t,
+		[]string{"localedef -i 'de_DE' -c -f 'UTF-8' 'de_DE.UTF-8'"},
+		locale.steps(),
+	)
+
+	require.Empty(t, Locale{Collate: "C"}.steps())
+}
@@ -0,0 +1,107 @@
+package psql
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	var (
+		mutex  sync.Mutex
+		logged []string
+	)
+
+	logf := func(format string, args ...any) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	dir := filepath.Join(t.TempDir(), "logs")
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithLogger(logf),
+		WithLogFiles(dir),
+	)
+	require.NoError(t, err)
+	require.NoError(t, grp.Cleanup(t.Context(), t))
+
+	for id, node := range grp.nodes {
+		prefix := "node " + strconv.Itoa(id) + " (" + node.hostname + "): "
+		found := false
+
+		mutex.Lock()
+
+		for _, line := range logged {
+			if strings.HasPrefix(line, prefix) &&
+				strings.Contains(line, "database system is ready to accept connections") {
+				found = true
+			}
+		}
+
+		mutex.Unlock()
+
+		require.True(t, found)
+
+		content, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(id)+"-"+node.hostname+".log"))
+		require.NoError(t, err)
+		require.Contains(t, string(content), "database system is ready to accept connections")
+		require.NotContains(t, string(content), prefix)
+	}
+}
+
+func TestLogsStartupTail(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5"},
+		WithInitdbArgs("--unknown-option"),
+		WithStartupTimeout(30*time.Second),
+		WithStartupLogTail(5),
+	)
+	require.ErrorIs(t, err, ErrGroupNodesNotRunning)
+	require.Nil(t, grp)
+	require.ErrorContains(t, err, "last lines of logs of node 0")
+	require.ErrorContains(t, err, "unknown-option")
+}
+
+func TestLogsOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithLogger(nil)(&opts), ErrLogfNil)
+	require.ErrorIs(t, WithLogFiles("")(&opts), ErrLogDirEmpty)
+	require.ErrorIs(t, WithStartupLogTail(0)(&opts), ErrLogTailNotPositive)
+
+	require.NoError(t, WithLogger(t.Logf)(&opts))
+	require.NoError(t, WithLogFiles(t.TempDir())(&opts))
+	require.NoError(t, WithStartupLogTail(10)(&opts))
+	require.Equal(t, 10, opts.logs.Tail)
+}
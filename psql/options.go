@@ -0,0 +1,266 @@
+package psql
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/logs"
+	"github.com/akramarenkov/illusion/internal/storage"
+)
+
+// Provides adjusting of a postgres group.
+type Adjuster func(opts *options) error
+
+type options struct {
+	citus                bool
+	clientCertAuth       bool
+	dataDir              string
+	database             string
+	extensions           []Extension
+	image                string
+	libraries            []string
+	locale               Locale
+	initdbArgs           string
+	ioThrottles          storage.Throttles
+	logs                 logs.Opts
+	logicalReplication   bool
+	nodeLocales          map[int]Locale
+	nodeResources        map[int]Resources
+	password             string
+	passwordCharset      string
+	passwordLength       int
+	pgBouncer            *PgBouncer
+	resources            *Resources
+	roles                []Role
+	startupTimeout       time.Duration
+	streamingReplication bool
+	tls                  bool
+	tmpfs                bool
+	tmpfsSize            int64
+	user                 string
+	volumesPrefix        string
+	walArchiving         bool
+}
+
+func (opts options) normalize() options {
+	if opts.dataDir == "" {
+		opts.dataDir = defaultDataDir
+	}
+
+	if opts.image == "" {
+		opts.image = defaultImage
+	}
+
+	if opts.startupTimeout == 0 {
+		opts.startupTimeout = defaultStartupTimeout
+	}
+
+	if opts.user == "" {
+		opts.user = defaultUser
+	}
+
+	// Same as in the image
+	if opts.database == "" {
+		opts.database = opts.user
+	}
+
+	return opts
+}
+
+func (opts options) validate() error {
+	if opts.tmpfs && opts.volumesPrefix != "" {
+		return ErrStorageConflict
+	}
+
+	return nil
+}
+
+// Enables TLS on nodes of the group.
+//
+// Certificate authority, server and client certificates are generated for each
+// group. Returned DSNs contain sslmode=verify-full and paths to the generated
+// files in the sslrootcert, sslcert and sslkey parameters. Files are removed by
+// the [Cleanup] function.
+func WithTLS() Adjuster {
+	adj := func(opts *options) error {
+		opts.tls = true
+		return nil
+	}
+
+	return adj
+}
+
+// Enables TLS on nodes of the group (see [WithTLS]) and requires clients to
+// authenticate with a certificate in addition to a password
+// (clientcert=verify-full in pg_hba.conf). Certificate is issued for the superuser
+// and each role that can log in (see [Group.RoleDSNs]).
+func WithClientCertAuth() Adjuster {
+	adj := func(opts *options) error {
+		opts.tls = true
+		opts.clientCertAuth = true
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets path to the data directory (PGDATA) in the containers. Path must be absolute.
+// By default /var/lib/postgresql/data is used.
+func WithDataDir(dir string) Adjuster {
+	adj := func(opts *options) error {
+		if !path.IsAbs(dir) {
+			return ErrDataDirNotAbsolute
+		}
+
+		opts.dataDir = path.Clean(dir)
+
+		return nil
+	}
+
+	return adj
+}
+
+// Places the data directory on tmpfs and turns off fsync and synchronous_commit.
+// Speeds up tests that do not need durability. Cannot be used together with
+// [WithPersistentVolumes].
+func WithTmpfs() Adjuster {
+	adj := func(opts *options) error {
+		opts.tmpfs = true
+		return nil
+	}
+
+	return adj
+}
+
+// Places the data directory of each node in a named volume that is not removed by
+// the [Cleanup] function, so data survives between runs. Volume of the node is named
+// as prefix-index, where index is the index of the node in the group. Cannot be used
+// together with [WithTmpfs].
+//
+// The password of the superuser is reset to the newly generated one on each run.
+// The superuser itself (see [WithUser]) must stay the same across runs that share
+// the prefix, otherwise [ErrVolumeUserMismatch] is returned.
+func WithPersistentVolumes(prefix string) Adjuster {
+	adj := func(opts *options) error {
+		if strings.TrimSpace(prefix) == "" {
+			return ErrVolumesPrefixEmpty
+		}
+
+		opts.volumesPrefix = prefix
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets timeout for the nodes readiness. Node is considered ready when the image
+// completes initialization and the SQL query is successfully executed using the
+// driver requested for the node. By default one minute is used.
+func WithStartupTimeout(timeout time.Duration) Adjuster {
+	adj := func(opts *options) error {
+		if timeout <= 0 {
+			return ErrStartupTimeoutNotPositive
+		}
+
+		opts.startupTimeout = timeout
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets name of the superuser (POSTGRES_USER). By default postgres is used.
+func WithUser(name string) Adjuster {
+	adj := func(opts *options) error {
+		if name == "" {
+			return ErrUserEmpty
+		}
+
+		opts.user = name
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets name of the database created on the nodes and specified in the returned
+// DSNs (POSTGRES_DB). By default the name of the superuser is used.
+func WithDatabase(name string) Adjuster {
+	adj := func(opts *options) error {
+		if name == "" {
+			return ErrDatabaseEmpty
+		}
+
+		opts.database = name
+
+		return nil
+	}
+
+	return adj
+}
+
+// Creates additional roles on each node. Roles are created in the order of
+// specification, so the role can be granted to the role specified after it.
+func WithRoles(roles ...Role) Adjuster {
+	adj := func(opts *options) error {
+		for _, role := range roles {
+			if role.Name == "" {
+				return ErrRoleNameEmpty
+			}
+		}
+
+		opts.roles = append(opts.roles, roles...)
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets fixed password of the superuser instead of the generated one.
+func WithPassword(password string) Adjuster {
+	adj := func(opts *options) error {
+		if password == "" {
+			return ErrPasswordEmpty
+		}
+
+		opts.password = password
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets length and charset of the generated password of the superuser. If charset
+// is not specified, Latin letters and digits are used. By default a password of 16
+// characters with digits and symbols is generated.
+func WithPasswordPolicy(length int, charset string) Adjuster {
+	adj := func(opts *options) error {
+		if length <= 0 {
+			return ErrPasswordLengthNotPositive
+		}
+
+		opts.passwordLength = length
+		opts.passwordCharset = charset
+
+		return nil
+	}
+
+	return adj
+}
+
+// Sets additional arguments of initdb (POSTGRES_INITDB_ARGS).
+func WithInitdbArgs(args string) Adjuster {
+	adj := func(opts *options) error {
+		opts.initdbArgs = args
+		return nil
+	}
+
+	return adj
+}
@@ -0,0 +1,50 @@
+package psql
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/sethvargo/go-password/password"
+)
+
+const defaultPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (opts options) generatePassword() (string, error) {
+	if opts.password != "" {
+		return opts.password, nil
+	}
+
+	if opts.passwordLength != 0 {
+		return generatePassword(opts.passwordLength, opts.passwordCharset)
+	}
+
+	return password.Generate(
+		defaultPasswordLength,
+		defaultPasswordNumDigits,
+		defaultPasswordNumSymbols,
+		false,
+		false,
+	)
+}
+
+func generatePassword(length int, charset string) (string, error) {
+	if charset == "" {
+		charset = defaultPasswordCharset
+	}
+
+	runes := []rune(charset)
+	limit := big.NewInt(int64(len(runes)))
+
+	generated := make([]rune, length)
+
+	for id := range generated {
+		index, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+
+		generated[id] = runes[index.Int64()]
+	}
+
+	return string(generated), nil
+}
@@ -0,0 +1,62 @@
+package psql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPause(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "17", []string{"pgx5"})
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	db, err := openDSN(grp.DSNs()[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	ping := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(t.Context(), timeout)
+		defer cancel()
+
+		return db.PingContext(ctx)
+	}
+
+	require.NoError(t, ping(time.Second))
+
+	require.NoError(t, grp.Pause(t.Context(), 0))
+	require.Error(t, ping(time.Second))
+	require.NoError(t, grp.Unpause(t.Context(), 0))
+	require.NoError(t, ping(10*time.Second))
+
+	started := time.Now()
+
+	resumed, err := grp.PauseFor(t.Context(), 0, 3*time.Second)
+	require.NoError(t, err)
+	require.NoError(t, ping(10*time.Second))
+	require.GreaterOrEqual(t, time.Since(started), 3*time.Second)
+	require.NoError(t, <-resumed)
+
+	require.ErrorIs(t, grp.Pause(t.Context(), 1), ErrNodeNotFound)
+	require.ErrorIs(t, grp.Unpause(t.Context(), -1), ErrNodeNotFound)
+
+	_, err = grp.PauseFor(t.Context(), 0, 0)
+	require.ErrorIs(t, err, ErrNodeNotPaused)
+}
@@ -0,0 +1,332 @@
+package psql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/parallel"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Pool modes of PgBouncer.
+const (
+	PoolModeSession     = "session"
+	PoolModeStatement   = "statement"
+	PoolModeTransaction = "transaction"
+)
+
+const (
+	DefaultPgBouncerImage = "edoburu/pgbouncer:v1.24.1-p1"
+
+	pgBouncerPort    = "6432"
+	pgBouncerPortTCP = "6432/tcp"
+
+	pgBouncerConfigDir  = "/etc/pgbouncer"
+	pgBouncerConfigFile = pgBouncerConfigDir + "/pgbouncer.ini"
+	pgBouncerUsersFile  = pgBouncerConfigDir + "/userlist.txt"
+	pgBouncerTLSDir     = pgBouncerConfigDir + "/tls"
+)
+
+var (
+	ErrPgBouncersNotRunning = errors.New("pgbouncers of postgres group was not running")
+	ErrPoolModeInvalid      = errors.New("pool mode is invalid")
+	ErrPoolSizeNegative     = errors.New("pool size is negative")
+)
+
+// Parameters of PgBouncer started in front of the nodes.
+type PgBouncer struct {
+	// Image of PgBouncer. Image must run pgbouncer with the configuration file
+	// /etc/pgbouncer/pgbouncer.ini if it exists. By default [DefaultPgBouncerImage]
+	// is used
+	Image string
+
+	// Pool mode. By default [PoolModeTransaction] is used
+	PoolMode string
+
+	// Corresponds to the default_pool_size parameter. If zero, the default value of
+	// PgBouncer is used
+	DefaultPoolSize int
+
+	// Corresponds to the max_client_conn parameter. If zero, the default value of
+	// PgBouncer is used
+	MaxClientConn int
+
+	// Corresponds to the max_prepared_statements parameter, which enables support of
+	// protocol-level prepared statements in the transaction and statement pool
+	// modes. If zero, the default value of PgBouncer is used
+	MaxPreparedStatements int
+
+	// Indices of nodes in front of which PgBouncer is started. If not specified,
+	// PgBouncer is started in front of each node
+	Nodes []int
+}
+
+func (bnc PgBouncer) normalize() PgBouncer {
+	if bnc.Image == "" {
+		bnc.Image = DefaultPgBouncerImage
+	}
+
+	if bnc.PoolMode == "" {
+		bnc.PoolMode = PoolModeTransaction
+	}
+
+	return bnc
+}
+
+func (bnc PgBouncer) validate() error {
+	switch bnc.PoolMode {
+	case "", PoolModeSession, PoolModeStatement, PoolModeTransaction:
+	default:
+		return fmt.Errorf("%w: %s", ErrPoolModeInvalid, bnc.PoolMode)
+	}
+
+	if bnc.DefaultPoolSize < 0 || bnc.MaxClientConn < 0 || bnc.MaxPreparedStatements < 0 {
+		return ErrPoolSizeNegative
+	}
+
+	for _, id := range bnc.Nodes {
+		if id < 0 {
+			return fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+		}
+	}
+
+	return nil
+}
+
+// Starts PgBouncer on the network of the group in front of the nodes. DSNs of the
+// PgBouncers are returned by the [Group.PooledDSNs] method.
+//
+// Connections between clients and PgBouncer are not encrypted even if TLS is
+// enabled on the nodes. PgBouncer connects to the nodes with the certificate of the
+// superuser, so with [WithClientCertAuth] this certificate is also accepted for the
+// roles that can log in.
+func WithPgBouncer(bouncer PgBouncer) Adjuster {
+	adj := func(opts *options) error {
+		if err := bouncer.validate(); err != nil {
+			return err
+		}
+
+		normalized := bouncer.normalize()
+		opts.pgBouncer = &normalized
+
+		return nil
+	}
+
+	return adj
+}
+
+// Returns DSNs of PgBouncers by the indices of the nodes in front of which they
+// are started.
+func (grp *Group) PooledDSNs() map[int]url.URL {
+	dsns := make(map[int]url.URL, len(grp.pooledDSNs))
+
+	for id, dsn := range grp.pooledDSNs {
+		dsns[id] = dsn
+	}
+
+	return dsns
+}
+
+func (grp *Group) runPgBouncers(ctx context.Context) error {
+	if grp.opts.pgBouncer == nil {
+		return nil
+	}
+
+	ids := grp.opts.pgBouncer.Nodes
+
+	if len(ids) == 0 {
+		ids = make([]int, len(grp.nodes))
+
+		for id := range ids {
+			ids[id] = id
+		}
+	}
+
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+
+	grp.bouncers = make(map[int]*node, len(ids))
+
+	for _, id := range ids {
+		upstream, err := grp.node(id)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPgBouncersNotRunning, err)
+		}
+
+		bouncer, err := grp.preparePgBouncer(upstream)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPgBouncersNotRunning, err)
+		}
+
+		grp.bouncers[id] = bouncer
+	}
+
+	if err := parallel.Run(ctx, slices.Collect(maps.Values(grp.bouncers))); err != nil {
+		return fmt.Errorf("%w: %w", ErrPgBouncersNotRunning, err)
+	}
+
+	grp.pooledDSNs = make(map[int]url.URL, len(grp.bouncers))
+
+	for id, bouncer := range grp.bouncers {
+		host, err := bouncer.container.Host(ctx)
+		if err != nil {
+			return err
+		}
+
+		port, err := bouncer.container.MappedPort(ctx, pgBouncerPortTCP)
+		if err != nil {
+			return err
+		}
+
+		dsn := grp.prepareDSN(bouncer, net.JoinHostPort(host, port.Port()))
+		dsn.RawQuery = url.Values{"sslmode": []string{"disable"}}.Encode()
+
+		grp.pooledDSNs[id] = dsn
+	}
+
+	return nil
+}
+
+func (grp *Group) terminatePgBouncers() error {
+	return parallel.Terminate(slices.Collect(maps.Values(grp.bouncers)))
+}
+
+func (grp *Group) preparePgBouncer(upstream *node) (*node, error) {
+	hostname, err := prepareHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{
+		pgBouncerConfigFile: grp.preparePgBouncerConfig(upstream),
+		pgBouncerUsersFile:  grp.preparePgBouncerUsers(upstream),
+	}
+
+	if grp.tls != nil {
+		files[pgBouncerTLSDir+"/"+rootCertFile] = grp.tls.authority.Pair().Cert
+		files[pgBouncerTLSDir+"/"+clientCertFile] = grp.tls.client.Cert
+	}
+
+	request := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Name:     hostname,
+			Hostname: hostname,
+			Image:    grp.opts.pgBouncer.Image,
+			ExposedPorts: []string{
+				pgBouncerPort,
+			},
+			Networks:   []string{grp.network.Name},
+			WaitingFor: wait.ForListeningPort(pgBouncerPortTCP),
+		},
+		Started: true,
+	}
+
+	for path, data := range files {
+		file := testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(data),
+			ContainerFilePath: path,
+			FileMode:          certsFileMode,
+		}
+
+		request.Files = append(request.Files, file)
+	}
+
+	if grp.tls != nil {
+		hooks := testcontainers.ContainerLifecycleHooks{
+			PostCreates: []testcontainers.ContainerHook{
+				copyPrivateKey(pgBouncerTLSDir+"/"+clientKeyFile, grp.tls.client.Key),
+			},
+		}
+
+		request.LifecycleHooks = append(request.LifecycleHooks, hooks)
+	}
+
+	grp.prepareKeep(&request)
+
+	bouncer := &node{
+		database: upstream.database,
+		driver:   upstream.driver,
+		hostname: hostname,
+		password: upstream.password,
+		req:      request,
+		user:     upstream.user,
+	}
+
+	return bouncer, nil
+}
+
+func (grp *Group) preparePgBouncerConfig(upstream *node) []byte {
+	bnc := grp.opts.pgBouncer
+
+	settings := map[string]string{
+		"listen_addr":               "0.0.0.0",
+		"listen_port":               pgBouncerPort,
+		"auth_type":                 "scram-sha-256",
+		"auth_file":                 pgBouncerUsersFile,
+		"pool_mode":                 bnc.PoolMode,
+		"ignore_startup_parameters": "extra_float_digits",
+	}
+
+	if bnc.DefaultPoolSize != 0 {
+		settings["default_pool_size"] = strconv.Itoa(bnc.DefaultPoolSize)
+	}
+
+	if bnc.MaxClientConn != 0 {
+		settings["max_client_conn"] = strconv.Itoa(bnc.MaxClientConn)
+	}
+
+	if bnc.MaxPreparedStatements != 0 {
+		settings["max_prepared_statements"] = strconv.Itoa(bnc.MaxPreparedStatements)
+	}
+
+	if grp.tls != nil {
+		settings["server_tls_sslmode"] = "verify-full"
+		settings["server_tls_ca_file"] = pgBouncerTLSDir + "/" + rootCertFile
+		settings["server_tls_cert_file"] = pgBouncerTLSDir + "/" + clientCertFile
+		settings["server_tls_key_file"] = pgBouncerTLSDir + "/" + clientKeyFile
+	}
+
+	var config strings.Builder
+
+	config.WriteString("[databases]\n")
+	config.WriteString("* = host=" + upstream.hostname + " port=" + sqlPort + "\n\n")
+	config.WriteString("[pgbouncer]\n")
+
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		config.WriteString(key + " = " + settings[key] + "\n")
+	}
+
+	return []byte(config.String())
+}
+
+func (grp *Group) preparePgBouncerUsers(upstream *node) []byte {
+	var users strings.Builder
+
+	users.WriteString(quotePgBouncerUser(upstream.user, upstream.password))
+
+	for _, role := range grp.opts.roles {
+		if role.Password == "" {
+			continue
+		}
+
+		users.WriteString(quotePgBouncerUser(role.Name, role.Password))
+	}
+
+	return []byte(users.String())
+}
+
+func quotePgBouncerUser(user string, password string) string {
+	user = strings.ReplaceAll(user, `"`, `""`)
+	password = strings.ReplaceAll(password, `"`, `""`)
+
+	return `"` + user + `" "` + password + `"` + "\n"
+}
@@ -0,0 +1,201 @@
+package psql
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgBouncer(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"postgres", "pgx5"},
+		WithTLS(),
+		WithPgBouncer(PgBouncer{PoolMode: PoolModeSession}),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	pooled := grp.PooledDSNs()
+	require.Len(t, pooled, len(grp.DSNs()))
+
+	for _, dsn := range pooled {
+		require.Equal(t, "disable", dsn.Query().Get("sslmode"))
+
+		migrations, err := migrate.New("file://testdata/migrations", dsn.String())
+		require.NoError(t, err)
+		require.NoError(t, migrations.Up())
+		require.NoError(t, migrations.Down())
+	}
+
+	for _, bouncer := range grp.bouncers {
+		output, err := execute.Run(
+			t.Context(),
+			bouncer.container,
+			[]string{"stat", "-c", "%a", pgBouncerTLSDir + "/" + clientKeyFile},
+		)
+		require.NoError(t, err)
+		require.Equal(t, "600", strings.TrimSpace(string(output)))
+	}
+}
+
+func TestPgBouncerClientCertAuth(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	reader := Role{
+		Name:     "reader",
+		Password: "reader-password",
+		Grants:   []string{"pg_read_all_data"},
+	}
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5"},
+		WithClientCertAuth(),
+		WithRoles(reader),
+		WithPgBouncer(PgBouncer{}),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dsn := grp.PooledDSNs()[0]
+	dsn.Scheme = "postgres"
+	dsn.User = url.UserPassword(reader.Name, reader.Password)
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	var user string
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT current_user").Scan(&user))
+	require.Equal(t, reader.Name, user)
+}
+
+func TestPgBouncerTransactionMode(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	bouncer := PgBouncer{
+		DefaultPoolSize:       2,
+		MaxClientConn:         10,
+		MaxPreparedStatements: 10,
+		Nodes:                 []int{1},
+	}
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithPgBouncer(bouncer),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	pooled := grp.PooledDSNs()
+	require.Len(t, pooled, 1)
+	require.Contains(t, pooled, 1)
+
+	dsn := pooled[1]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	for id := range 10 {
+		var value int
+
+		require.NoError(t, db.QueryRowContext(t.Context(), "SELECT $1::int", id).Scan(&value))
+		require.Equal(t, id, value)
+	}
+}
+
+func TestWithPgBouncerWrongArgs(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithPgBouncer(PgBouncer{PoolMode: "none"})(&opts), ErrPoolModeInvalid)
+	require.ErrorIs(t, WithPgBouncer(PgBouncer{DefaultPoolSize: -1})(&opts), ErrPoolSizeNegative)
+	require.ErrorIs(t, WithPgBouncer(PgBouncer{Nodes: []int{-1}})(&opts), ErrNodeNotFound)
+	require.Nil(t, opts.pgBouncer)
+
+	require.NoError(t, WithPgBouncer(PgBouncer{})(&opts))
+	require.Equal(t, DefaultPgBouncerImage, opts.pgBouncer.Image)
+	require.Equal(t, PoolModeTransaction, opts.pgBouncer.PoolMode)
+}
+
+func TestPreparePgBouncerConfig(t *testing.T) {
+	grp := &Group{
+		opts: options{
+			pgBouncer: &PgBouncer{
+				PoolMode:        PoolModeTransaction,
+				DefaultPoolSize: 5,
+			},
+			roles: []Role{
+				{Name: "reader", Password: `pass"word`},
+				{Name: "readers"},
+			},
+		},
+	}
+
+	upstream := &node{
+		hostname: "node",
+		password: "password",
+		user:     "postgres",
+	}
+
+	require.Equal(t,
+		"[databases]\n"+
+			"* = host=node port=5432\n\n"+
+			"[pgbouncer]\n"+
+			"auth_file = /etc/pgbouncer/userlist.txt\n"+
+			"auth_type = scram-sha-256\n"+
+			"default_pool_size = 5\n"+
+			"ignore_startup_parameters = extra_float_digits\n"+
+			"listen_addr = 0.0.0.0\n"+
+			"listen_port = 6432\n"+
+			"pool_mode = transaction\n",
+		string(grp.preparePgBouncerConfig(upstream)),
+	)
+
+	require.Equal(t,
+		`"postgres" "password"`+"\n"+`"reader" "pass""word"`+"\n",
+		string(grp.preparePgBouncerUsers(upstream)),
+	)
+}
@@ -4,49 +4,97 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/url"
-
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/hostconfig"
+	"github.com/akramarenkov/illusion/internal/keep"
+	"github.com/akramarenkov/illusion/internal/logs"
 	"github.com/akramarenkov/illusion/internal/parallel"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/errdefs"
 	"github.com/google/uuid"
-	"github.com/sethvargo/go-password/password"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/network"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
 var (
-	ErrGroupNetworkNotCreated = errors.New("network of postgres group was not created")
-	ErrGroupNodesNotRunning   = errors.New("nodes of postgres group was not running")
-	ErrGroupNotRemoved        = errors.New("postgres group was not removed")
+	ErrGroupNetworkNotCreated    = errors.New("network of postgres group was not created")
+	ErrGroupNodesNotRunning      = errors.New("nodes of postgres group was not running")
+	ErrGroupNotRemoved           = errors.New("postgres group was not removed")
+	ErrDataDirNotAbsolute        = errors.New("data directory path is not absolute")
+	ErrStorageConflict           = errors.New("tmpfs and persistent volumes are mutually exclusive")
+	ErrVolumesPrefixEmpty        = errors.New("prefix of volume names is empty")
+	ErrVolumeUserMismatch        = errors.New("data directory in volume was initialized with another user")
+	ErrStartupTimeoutNotPositive = errors.New("startup timeout is zero or negative")
+	ErrUserEmpty                 = errors.New("user name is empty")
+	ErrDatabaseEmpty             = errors.New("database name is empty")
+	ErrRoleNameEmpty             = errors.New("role name is empty")
+	ErrRoleNotFound              = errors.New("role with specified name was not found")
+	ErrRoleNotLogin              = errors.New("role can not log in")
+	ErrPasswordEmpty             = errors.New("password is empty")
+	ErrPasswordLengthNotPositive = errors.New("password length is zero or negative")
+	ErrNodeNotFound              = errors.New("node with specified index was not found")
 )
 
 const (
 	sqlPort    = "5432"
 	sqlPortTCP = "5432/tcp"
 
+	defaultDataDir            = "/var/lib/postgresql/data"
+	defaultImage              = "postgres"
 	defaultDriver             = "postgres"
+	defaultUser               = "postgres"
+	maintenanceDatabase       = "postgres"
 	defaultPasswordLength     = 16
 	defaultPasswordNumDigits  = 4
 	defaultPasswordNumSymbols = 4
+	defaultStartupTimeout     = time.Minute
 )
 
-type Cleanup func(ctx context.Context) error
+// Removes the group. If the ILLUSION_KEEP environment variable is set to
+// on-failure and any of the specified tests has failed, the group is kept running
+// for debugging instead (see [Group.Cleanup]).
+type Cleanup func(ctx context.Context, t ...TB) error
 
-type group struct {
+// Running postgres group. Nodes of the group are identified by their indices, which
+// correspond to the indices of the requested drivers and returned DSNs.
+type Group struct {
 	drivers  []string
 	imageTag string
-
-	network *testcontainers.DockerNetwork
-	nodes   []*node
+	opts     options
+
+	archiveVolume string
+	bouncers      map[int]*node
+	dsns          []url.URL
+	keepOnFailure bool
+	network       *testcontainers.DockerNetwork
+	nodeLogs      *logs.Nodes
+	nodes         []*node
+	pooledDSNs    map[int]url.URL
+	tls           *tlsEnv
+	volumes       []string
 }
 
 type node struct {
 	container testcontainers.Container
+	database  string
 	driver    string
+	hostname  string
+	imageTag  string
+	locale    Locale
 	password  string
+	primary   *node
+	recovery  *recovery
 	req       testcontainers.GenericContainerRequest
+	user      string
 }
 
 func (n *node) Get() testcontainers.Container {
@@ -61,61 +109,177 @@ func (n *node) Request() testcontainers.GenericContainerRequest {
 	return n.req
 }
 
+// Runs postgres group with a node for each of the drivers. Driver is used as a
+// scheme of the DSN returned for the node. If drivers are not specified, a single
+// node with the postgres driver is run.
+//
+// [Cleanup] function must be called when the group is no longer needed if [Run] did
+// not return an error.
 func Run(ctx context.Context, imageTag string, drivers ...string) ([]url.URL, Cleanup, error) {
+	return RunWith(ctx, imageTag, drivers)
+}
+
+// Same as [Run], but adjusts the group with the specified options.
+//
+// [Cleanup] function must be called when the group is no longer needed if
+// [RunWith] did not return an error.
+func RunWith(
+	ctx context.Context,
+	imageTag string,
+	drivers []string,
+	opts ...Adjuster,
+) ([]url.URL, Cleanup, error) {
+	grp, err := StartGroup(ctx, imageTag, drivers, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return grp.DSNs(), grp.Cleanup, nil
+}
+
+// Same as [RunWith], but returns the group that provides operations on the running
+// nodes.
+//
+// [Group.Cleanup] method must be called when the group is no longer needed if
+// [StartGroup] did not return an error.
+func StartGroup(
+	ctx context.Context,
+	imageTag string,
+	drivers []string,
+	opts ...Adjuster,
+) (*Group, error) {
 	if len(drivers) == 0 {
 		drivers = []string{defaultDriver}
 	}
 
-	grp := &group{
+	grp := &Group{
 		drivers:  drivers,
 		imageTag: imageTag,
 	}
 
-	dsns, err := grp.run(ctx)
-	if err != nil {
-		return nil, nil, errors.Join(err, grp.cleanup(ctx))
+	return grp.start(ctx, opts)
+}
+
+func (grp *Group) start(ctx context.Context, opts []Adjuster) (*Group, error) {
+	for _, adj := range opts {
+		if err := adj(&grp.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := grp.opts.validate(); err != nil {
+		return nil, err
+	}
+
+	grp.opts = grp.opts.normalize()
+	grp.keepOnFailure = keep.Enabled()
+	grp.nodeLogs = logs.NewNodes(grp.opts.logs)
+
+	if grp.opts.streamingReplication && len(grp.drivers) < 2 { //nolint:mnd // Primary and at least one standby
+		return nil, ErrStandbysQuantityZero
+	}
+
+	if err := grp.validateLocales(); err != nil {
+		return nil, err
 	}
 
-	return dsns, grp.cleanup, nil
+	if err := grp.run(ctx); err != nil {
+		// Context may be expired while waiting for the readiness of the nodes
+		err = grp.checkOOMKilled(context.WithoutCancel(ctx), err)
+		err = logs.AddTails(context.WithoutCancel(ctx), grp.nodeLogs, grp.nodes, err)
+
+		return nil, errors.Join(err, grp.Cleanup(ctx))
+	}
+
+	return grp, nil
+}
+
+// Returns DSNs of the nodes.
+func (grp *Group) DSNs() []url.URL {
+	return slices.Clone(grp.dsns)
 }
 
-func (grp *group) run(ctx context.Context) ([]url.URL, error) {
+func (grp *Group) run(ctx context.Context) error {
 	if err := grp.createNetwork(ctx); err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := grp.prepareTLS(ctx); err != nil {
+		return err
+	}
+
+	if err := grp.prepareArchiving(); err != nil {
+		return err
 	}
 
 	if err := grp.runNodes(ctx); err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := grp.initializeCitus(ctx); err != nil {
+		return err
 	}
 
-	dsns := make([]url.URL, len(grp.nodes))
+	grp.dsns = make([]url.URL, len(grp.nodes))
 
 	for id, node := range grp.nodes {
-		host, err := node.container.Host(ctx)
+		dsn, err := grp.nodeDSN(ctx, node)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		port, err := node.container.MappedPort(ctx, sqlPortTCP)
-		if err != nil {
-			return nil, err
-		}
+		grp.dsns[id] = dsn
+	}
 
-		dsn := url.URL{
-			Scheme:   node.driver,
-			User:     url.UserPassword("postgres", node.password),
-			Host:     net.JoinHostPort(host, port.Port()),
-			Path:     "/",
-			RawQuery: url.Values{"sslmode": []string{"disable"}}.Encode(),
-		}
+	return grp.runPgBouncers(ctx)
+}
+
+func (grp *Group) nodeDSN(ctx context.Context, node *node) (url.URL, error) {
+	host, err := node.container.Host(ctx)
+	if err != nil {
+		return url.URL{}, err
+	}
 
-		dsns[id] = dsn
+	port, err := node.container.MappedPort(ctx, sqlPortTCP)
+	if err != nil {
+		return url.URL{}, err
 	}
 
-	return dsns, nil
+	return grp.prepareDSN(node, net.JoinHostPort(host, port.Port())), nil
 }
 
-func (grp *group) cleanup(ctx context.Context) error {
+func (grp *Group) prepareDSN(node *node, address string) url.URL {
+	dsn := url.URL{
+		Scheme:   node.driver,
+		User:     url.UserPassword(node.user, node.password),
+		Host:     address,
+		Path:     "/" + node.database,
+		RawQuery: grp.tlsQuery().Encode(),
+	}
+
+	return dsn
+}
+
+// Terminates the nodes and removes the network and generated files of the group.
+//
+// If the ILLUSION_KEEP environment variable is set to on-failure and any of the
+// specified tests has failed, the group is kept running for debugging instead.
+// DSNs of the group, names of its resources and the command that removes them are
+// logged by the failed test. Kept groups are not removed at the end of the
+// testcontainers session, but can be removed by the [RemoveKept] function.
+func (grp *Group) Cleanup(ctx context.Context, t ...TB) error {
+	if failed := grp.failedTest(t); failed != nil {
+		return grp.keep(ctx, failed)
+	}
+
+	return grp.cleanup(ctx)
+}
+
+func (grp *Group) cleanup(ctx context.Context) error {
+	if err := grp.terminatePgBouncers(); err != nil {
+		return fmt.Errorf("%w: %w", ErrGroupNotRemoved, err)
+	}
+
 	if err := parallel.Terminate(grp.nodes); err != nil {
 		return fmt.Errorf("%w: %w", ErrGroupNotRemoved, err)
 	}
@@ -128,11 +292,48 @@ func (grp *group) cleanup(ctx context.Context) error {
 		grp.network = nil
 	}
 
+	if err := grp.removeVolumes(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrGroupNotRemoved, err)
+	}
+
+	if err := grp.removeTLS(); err != nil {
+		return fmt.Errorf("%w: %w", ErrGroupNotRemoved, err)
+	}
+
+	if err := grp.nodeLogs.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrGroupNotRemoved, err)
+	}
+
 	return nil
 }
 
-func (grp *group) createNetwork(ctx context.Context) error {
-	netwk, err := network.New(ctx)
+func (grp *Group) removeVolumes(ctx context.Context) error {
+	if len(grp.volumes) == 0 {
+		return nil
+	}
+
+	client, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	for len(grp.volumes) != 0 {
+		// Volume may not be created if the group failed to start
+		err := client.VolumeRemove(ctx, grp.volumes[0], true)
+		if err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+
+		grp.volumes = grp.volumes[1:]
+	}
+
+	return nil
+}
+
+func (grp *Group) createNetwork(ctx context.Context) error {
+	netwk, err := network.New(ctx, grp.networkCustomizers()...)
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrGroupNetworkNotCreated, err)
 	}
@@ -142,7 +343,7 @@ func (grp *group) createNetwork(ctx context.Context) error {
 	return nil
 }
 
-func (grp *group) runNodes(ctx context.Context) error {
+func (grp *Group) runNodes(ctx context.Context) error {
 	if err := grp.prepareNodeRequests(); err != nil {
 		return fmt.Errorf(
 			"%w: preparing node requests: %w",
@@ -151,6 +352,20 @@ func (grp *group) runNodes(ctx context.Context) error {
 		)
 	}
 
+	// Standbys are created from a base backup of the primary, so they are run after
+	// the primary is ready
+	if grp.opts.streamingReplication {
+		if err := parallel.Run(ctx, grp.nodes[:1]); err != nil {
+			return fmt.Errorf("%w: %w", ErrGroupNodesNotRunning, err)
+		}
+
+		if err := parallel.Run(ctx, grp.nodes[1:]); err != nil {
+			return fmt.Errorf("%w: %w", ErrGroupNodesNotRunning, err)
+		}
+
+		return nil
+	}
+
 	if err := parallel.Run(ctx, grp.nodes); err != nil {
 		return fmt.Errorf("%w: %w", ErrGroupNodesNotRunning, err)
 	}
@@ -158,7 +373,7 @@ func (grp *group) runNodes(ctx context.Context) error {
 	return nil
 }
 
-func (grp *group) prepareNodeRequests() error {
+func (grp *Group) prepareNodeRequests() error {
 	grp.nodes = make([]*node, len(grp.drivers))
 
 	for id, driver := range grp.drivers {
@@ -167,51 +382,88 @@ func (grp *group) prepareNodeRequests() error {
 			return err
 		}
 
-		pass, err := password.Generate(
-			defaultPasswordLength,
-			defaultPasswordNumDigits,
-			defaultPasswordNumSymbols,
-			false,
-			false,
-		)
+		pass, err := grp.opts.generatePassword()
 		if err != nil {
 			return err
 		}
 
-		request := testcontainers.GenericContainerRequest{
-			ContainerRequest: testcontainers.ContainerRequest{
-				Name:     hostname,
-				Hostname: hostname,
-				Image:    "postgres:" + grp.imageTag,
-				ExposedPorts: []string{
-					sqlPort,
-				},
-				Env: map[string]string{
-					"POSTGRES_PASSWORD": pass,
-				},
-				Networks: []string{grp.network.Name},
-				WaitingFor: wait.ForAll(
-					wait.ForListeningPort(sqlPortTCP),
-				),
-				Mounts: testcontainers.Mounts(
-					testcontainers.VolumeMount("", "/var/lib/postgres"),
-				),
-			},
-			Started: true,
-		}
-
-		prepared := &node{
+		grp.nodes[id] = &node{
+			database: grp.opts.database,
 			driver:   driver,
+			hostname: hostname,
+			imageTag: grp.imageTag,
+			locale:   grp.opts.nodeLocale(id),
 			password: pass,
-			req:      request,
+			user:     grp.opts.user,
+		}
+	}
+
+	grp.prepareStandbys()
+
+	for id, node := range grp.nodes {
+		if err := grp.prepareNodeRequest(id, node); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+func (grp *Group) prepareNodeRequest(id int, node *node) error {
+	request := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Name:     node.hostname,
+			Hostname: node.hostname,
+			Image:    grp.opts.image + ":" + node.imageTag,
+			ExposedPorts: []string{
+				sqlPort,
+			},
+			Env: map[string]string{
+				"PGDATA":               grp.opts.dataDir,
+				"POSTGRES_DB":          grp.opts.database,
+				"POSTGRES_INITDB_ARGS": grp.nodeInitdbArgs(node),
+				"POSTGRES_PASSWORD":    node.password,
+				"POSTGRES_USER":        grp.opts.user,
+			},
+			Entrypoint: prepareEntrypoint(grp.prepareSteps(node)),
+			Cmd:        prepareCmd(grp.prepareSettings(node)),
+			Networks:   []string{grp.network.Name},
+		},
+		Started: true,
+	}
 
-		grp.nodes[id] = prepared
+	node.locale.env(request.Env)
+	grp.prepareNodeStorage(id, &request)
+	grp.prepareNodeThrottle(id, &request)
+	grp.prepareNodeResources(id, &request)
+	grp.prepareNodeArchiving(&request)
+
+	if err := grp.prepareNodeTLS(node.hostname, &request); err != nil {
+		return err
+	}
+
+	if err := grp.nodeLogs.Prepare(id, node.hostname, &request); err != nil {
+		return err
 	}
 
+	grp.prepareNodeCitus(&request)
+	grp.prepareNodeReplication(node, &request)
+	grp.prepareKeep(&request)
+
+	request.WaitingFor = grp.prepareWaiting(id, node)
+	node.req = request
+
 	return nil
 }
 
+func (grp *Group) node(id int) (*node, error) {
+	if id < 0 || id >= len(grp.nodes) {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+	}
+
+	return grp.nodes[id], nil
+}
+
 func prepareHostname() (string, error) {
 	hostname, err := uuid.NewRandom()
 	if err != nil {
@@ -220,3 +472,81 @@ func prepareHostname() (string, error) {
 
 	return hostname.String(), nil
 }
+
+func (grp *Group) prepareNodeStorage(id int, req *testcontainers.GenericContainerRequest) {
+	switch {
+	case grp.opts.tmpfs:
+		req.Tmpfs = map[string]string{grp.opts.dataDir: grp.tmpfsOptions()}
+	case grp.opts.volumesPrefix != "":
+		// Volume is mounted bypassing the testcontainers, which adds session labels to
+		// the volumes it creates, so they are removed at the end of the session
+		volume := mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: grp.volumeName(id),
+			Target: grp.opts.dataDir,
+		}
+
+		hostconfig.Add(req, func(config *container.HostConfig) {
+			config.Mounts = append(config.Mounts, volume)
+		})
+	default:
+		req.Mounts = testcontainers.Mounts(
+			testcontainers.VolumeMount("", testcontainers.ContainerMountTarget(grp.opts.dataDir)),
+		)
+	}
+}
+
+func (grp *Group) volumeName(id int) string {
+	return grp.opts.volumesPrefix + "-" + strconv.Itoa(id)
+}
+
+func (grp *Group) prepareSteps(node *node) []string {
+	return slices.Concat(
+		node.locale.steps(),
+		grp.tlsSteps(),
+		grp.citusSteps(),
+		grp.archivingSteps(),
+		grp.recoverySteps(node),
+		grp.standbySteps(node),
+	)
+}
+
+func (grp *Group) prepareSettings(node *node) map[string]string {
+	settings := make(map[string]string)
+
+	node.locale.settings(settings)
+	grp.tlsSettings(settings)
+	grp.citusSettings(settings)
+	grp.extensionsSettings(settings)
+	grp.archivingSettings(node, settings)
+	grp.recoverySettings(node, settings)
+	grp.standbySettings(node, settings)
+
+	if grp.opts.logicalReplication {
+		settings["wal_level"] = "logical"
+	}
+
+	return settings
+}
+
+// Runs preparation steps as root before passing control to the entrypoint of
+// the image.
+func prepareEntrypoint(steps []string) []string {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	script := strings.Join(steps, " && ") + ` && exec docker-entrypoint.sh "$@"`
+
+	return []string{"sh", "-c", script, "sh"}
+}
+
+func prepareCmd(settings map[string]string) []string {
+	cmd := []string{"postgres"}
+
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		cmd = append(cmd, "-c", key+"="+settings[key])
+	}
+
+	return cmd
+}
@@ -1,7 +1,11 @@
 package psql
 
 import (
+	"database/sql"
+	"net/url"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/akramarenkov/illusion/internal/interceptor"
 
@@ -9,7 +13,9 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 )
 
 func TestMain(m *testing.M) {
@@ -31,11 +37,11 @@ func TestRun(t *testing.T) {
 	require.NoError(t, err)
 
 	defer func() {
-		require.NoError(t, cleanup(t.Context()))
+		require.NoError(t, cleanup(t.Context(), t))
 	}()
 
 	defer func() {
-		require.NoError(t, cleanup(t.Context()))
+		require.NoError(t, cleanup(t.Context(), t))
 	}()
 
 	for _, dsn := range dsns {
@@ -45,3 +51,409 @@ func TestRun(t *testing.T) {
 		require.NoError(t, migrations.Down())
 	}
 }
+
+func TestRunTLS(t *testing.T) {
+	testRunTLS(t, WithTLS())
+}
+
+func TestRunClientCertAuth(t *testing.T) {
+	testRunTLS(t, WithClientCertAuth())
+}
+
+func testRunTLS(t *testing.T, opts ...Adjuster) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", []string{"postgres", "pgx5"}, opts...)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	for _, dsn := range dsns {
+		query := dsn.Query()
+
+		require.Equal(t, "verify-full", query.Get("sslmode"))
+		require.FileExists(t, query.Get("sslrootcert"))
+		require.FileExists(t, query.Get("sslcert"))
+		require.FileExists(t, query.Get("sslkey"))
+
+		migrations, err := migrate.New("file://testdata/migrations", dsn.String())
+		require.NoError(t, err)
+		require.NoError(t, migrations.Up())
+		require.NoError(t, migrations.Down())
+	}
+
+	require.NoError(t, cleanup(t.Context(), t))
+
+	for _, dsn := range dsns {
+		require.NoFileExists(t, dsn.Query().Get("sslrootcert"))
+	}
+}
+
+func TestRunClientCertAuthRoles(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	reader := Role{
+		Name:     "reader",
+		Password: "reader-password",
+		Grants:   []string{"pg_read_all_data"},
+	}
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5"},
+		WithClientCertAuth(),
+		WithRoles(Role{Name: "readers"}, reader),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dsns, err := grp.RoleDSNs(reader.Name)
+	require.NoError(t, err)
+	require.Len(t, dsns, 1)
+	require.NotEqual(t, grp.DSNs()[0].Query().Get("sslcert"), dsns[0].Query().Get("sslcert"))
+
+	db, err := openDSN(dsns[0])
+	require.NoError(t, err)
+
+	var user string
+
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT current_user").Scan(&user))
+	require.Equal(t, reader.Name, user)
+	require.NoError(t, db.Close())
+
+	// Certificate of the superuser is not accepted for the role
+	foreign := dsns[0]
+	foreign.RawQuery = grp.DSNs()[0].RawQuery
+
+	db, err = openDSN(foreign)
+	require.NoError(t, err)
+	require.Error(t, db.PingContext(t.Context()))
+	require.NoError(t, db.Close())
+
+	_, err = grp.RoleDSNs("readers")
+	require.ErrorIs(t, err, ErrRoleNotLogin)
+
+	_, err = grp.RoleDSNs("writer")
+	require.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+func TestRunTmpfs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", []string{"postgres", "pgx5"}, WithTmpfs())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	for _, dsn := range dsns {
+		migrations, err := migrate.New("file://testdata/migrations", dsn.String())
+		require.NoError(t, err)
+		require.NoError(t, migrations.Up())
+		require.NoError(t, migrations.Down())
+	}
+}
+
+func TestRunPersistentVolumes(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	prefix := "illusion-" + uuid.NewString()
+
+	client, err := testcontainers.NewDockerClientWithOpts(t.Context())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, client.VolumeRemove(t.Context(), prefix+"-0", true))
+		require.NoError(t, client.Close())
+	}()
+
+	for run := range 2 {
+		dsns, cleanup, err := RunWith(t.Context(), "17", nil,
+			WithDataDir("/var/lib/postgresql/data"),
+			WithPersistentVolumes(prefix),
+		)
+		require.NoError(t, err)
+
+		migrations, err := migrate.New("file://testdata/migrations", dsns[0].String())
+		require.NoError(t, err)
+
+		if run == 0 {
+			require.NoError(t, migrations.Up())
+		} else {
+			require.ErrorIs(t, migrations.Up(), migrate.ErrNoChange)
+		}
+
+		require.NoError(t, cleanup(t.Context(), t))
+	}
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", nil,
+		WithDataDir("/var/lib/postgresql/data"),
+		WithPersistentVolumes(prefix),
+		WithUser("stranger"),
+	)
+	require.ErrorIs(t, err, ErrVolumeUserMismatch)
+	require.ErrorContains(t, err, prefix+"-0")
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+}
+
+func TestRunCredentials(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	reader := Role{
+		Name:     "reader",
+		Password: "reader-password",
+		Grants: []string{
+			"CONNECT ON DATABASE app",
+			"pg_read_all_data",
+		},
+	}
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", []string{"postgres", "pgx5"},
+		WithUser("owner"),
+		WithDatabase("app"),
+		WithPasswordPolicy(32, "abcdef0123456789"),
+		WithRoles(Role{Name: "readers"}, reader),
+		WithInitdbArgs("--data-checksums"),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	for _, dsn := range dsns {
+		require.Equal(t, "owner", dsn.User.Username())
+		require.Equal(t, "/app", dsn.Path)
+
+		password, _ := dsn.User.Password()
+		require.Regexp(t, "^[a-f0-9]{32}$", password)
+
+		migrations, err := migrate.New("file://testdata/migrations", dsn.String())
+		require.NoError(t, err)
+		require.NoError(t, migrations.Up())
+		require.NoError(t, migrations.Down())
+
+		dsn.Scheme = "postgres"
+		dsn.User = url.UserPassword(reader.Name, reader.Password)
+
+		db, err := sql.Open("pgx", dsn.String())
+		require.NoError(t, err)
+
+		var checksums string
+
+		require.NoError(t, db.QueryRowContext(t.Context(), "SHOW data_checksums").Scan(&checksums))
+		require.Equal(t, "on", checksums)
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestRunFixedPassword(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", nil, WithPassword("password"))
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	password, _ := dsns[0].User.Password()
+	require.Equal(t, "password", password)
+}
+
+func TestRunWrongOptions(t *testing.T) {
+	dsns, cleanup, err := RunWith(t.Context(), "17", nil, WithTmpfs(), WithPersistentVolumes("x"))
+	require.ErrorIs(t, err, ErrStorageConflict)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+
+	dsns, cleanup, err = RunWith(t.Context(), "17", nil, WithPersistentVolumes(" "))
+	require.ErrorIs(t, err, ErrVolumesPrefixEmpty)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+
+	dsns, cleanup, err = RunWith(t.Context(), "17", nil, WithDataDir("data"))
+	require.ErrorIs(t, err, ErrDataDirNotAbsolute)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+
+	dsns, cleanup, err = RunWith(t.Context(), "17", nil, WithStartupTimeout(0))
+	require.ErrorIs(t, err, ErrStartupTimeoutNotPositive)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+
+	wrong := []struct {
+		adj Adjuster
+		err error
+	}{
+		{adj: WithUser(""), err: ErrUserEmpty},
+		{adj: WithDatabase(""), err: ErrDatabaseEmpty},
+		{adj: WithRoles(Role{}), err: ErrRoleNameEmpty},
+		{adj: WithPassword(""), err: ErrPasswordEmpty},
+		{adj: WithPasswordPolicy(0, ""), err: ErrPasswordLengthNotPositive},
+	}
+
+	for _, item := range wrong {
+		dsns, cleanup, err = RunWith(t.Context(), "17", nil, item.adj)
+		require.ErrorIs(t, err, item.err)
+		require.Nil(t, cleanup)
+		require.Nil(t, dsns)
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := generatePassword(64, "")
+	require.NoError(t, err)
+	require.Regexp(t, "^[a-zA-Z0-9]{64}$", password)
+
+	password, err = generatePassword(8, "ж")
+	require.NoError(t, err)
+	require.Equal(t, "жжжжжжжж", password)
+}
+
+func TestRoleStatements(t *testing.T) {
+	role := Role{
+		Name:     "reader",
+		Password: "it's",
+		Grants:   []string{"pg_read_all_data"},
+	}
+
+	require.Equal(t,
+		[]string{
+			"DO $$ BEGIN IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'reader') " +
+				`THEN CREATE ROLE "reader"; END IF; END $$`,
+			`ALTER ROLE "reader" LOGIN PASSWORD 'it''s'`,
+			`GRANT pg_read_all_data TO "reader"`,
+		},
+		role.statements(),
+	)
+
+	require.Equal(t,
+		`ALTER ROLE "group" NOLOGIN PASSWORD NULL`,
+		Role{Name: "group"}.statements()[1],
+	)
+}
+
+func TestRunStartupTimeoutExpired(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", nil, WithStartupTimeout(time.Millisecond))
+	require.Error(t, err)
+	require.Nil(t, cleanup)
+	require.Nil(t, dsns)
+}
+
+func TestReadyLogPattern(t *testing.T) {
+	pattern := regexp.MustCompile(readyLogPattern)
+
+	initializing := "initdb: warning: enabling \"trust\" authentication for local connections\n" +
+		"LOG:  database system is ready to accept connections\n" +
+		"waiting for server to shut down.... done\n"
+
+	initialized := initializing +
+		"PostgreSQL init process complete; ready for start up.\n" +
+		"LOG:  database system was shut down at 2025-01-01 00:00:00 UTC\n" +
+		"LOG:  database system is ready to accept connections\n"
+
+	skipping := "PostgreSQL Database directory appears to contain a database; " +
+		"Skipping initialization\n" +
+		"LOG:  database system is ready to accept connections\n"
+
+	standby := "PostgreSQL Database directory appears to contain a database; " +
+		"Skipping initialization\n" +
+		"LOG:  database system is ready to accept read-only connections\n"
+
+	require.False(t, pattern.MatchString(initializing))
+	require.True(t, pattern.MatchString(initialized))
+	require.True(t, pattern.MatchString(skipping))
+	require.False(t, pattern.MatchString(standby))
+
+	standbyPattern := regexp.MustCompile(standbyReadyLogPattern)
+
+	require.True(t, standbyPattern.MatchString(standby))
+	require.False(t, standbyPattern.MatchString(skipping))
+}
+
+func TestSQLDriver(t *testing.T) {
+	require.Equal(t, "postgres", sqlDriver("postgres"))
+	require.Equal(t, "postgres", sqlDriver("postgresql"))
+	require.Equal(t, "pgx", sqlDriver("pgx"))
+	require.Equal(t, "pgx", sqlDriver("pgx5"))
+}
+
+func TestPrepareCmd(t *testing.T) {
+	require.Equal(t, []string{"postgres"}, prepareCmd(nil))
+
+	settings := map[string]string{
+		"ssl":      "on",
+		"fsync":    "off",
+		"hba_file": "/pg_hba.conf",
+	}
+
+	require.Equal(t,
+		[]string{
+			"postgres",
+			"-c", "fsync=off",
+			"-c", "hba_file=/pg_hba.conf",
+			"-c", "ssl=on",
+		},
+		prepareCmd(settings),
+	)
+}
+
+func TestPrepareEntrypoint(t *testing.T) {
+	require.Nil(t, prepareEntrypoint(nil))
+
+	require.Equal(t,
+		[]string{
+			"sh",
+			"-c",
+			`mkdir /a && chown postgres /a && exec docker-entrypoint.sh "$@"`,
+			"sh",
+		},
+		prepareEntrypoint([]string{"mkdir /a", "chown postgres /a"}),
+	)
+}
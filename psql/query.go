@@ -0,0 +1,97 @@
+package psql
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
+)
+
+// Executes SQL statements via psql in the node container over the local socket and
+// returns output in unaligned tuples-only form with trailing newlines trimmed.
+func query(
+	ctx context.Context,
+	target execute.Executor,
+	user string,
+	database string,
+	statements ...string,
+) (string, error) {
+	cmd := []string{
+		"psql",
+		"--no-psqlrc",
+		"--no-align",
+		"--tuples-only",
+		"--quiet",
+		"--set", "ON_ERROR_STOP=1",
+		"--username", user,
+		"--dbname", database,
+	}
+
+	for _, statement := range statements {
+		cmd = append(cmd, "--command", statement)
+	}
+
+	output, err := execute.Run(
+		ctx,
+		target,
+		cmd,
+		tcexec.WithEnv([]string{"PGOPTIONS=-c client_min_messages=warning"}),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (n *node) query(ctx context.Context, database string, statements ...string) (string, error) {
+	return query(ctx, n.container, n.user, database, statements...)
+}
+
+func quoteLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// Quotes identifier that can be qualified with a schema name, e.g. public.table.
+func quoteQualifiedIdentifier(identifier string) string {
+	parts := strings.Split(identifier, ".")
+
+	for id, part := range parts {
+		parts[id] = quoteIdentifier(part)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Returns connection string in the libpq key/value format that is used to connect
+// from one node to another.
+func (grp *Group) prepareConnString(target *node, database string) string {
+	params := grp.tlsConnParams()
+
+	params["host"] = target.hostname
+	params["port"] = sqlPort
+	params["user"] = target.user
+	params["password"] = target.password
+	params["dbname"] = database
+
+	return prepareConnString(params)
+}
+
+func prepareConnString(params map[string]string) string {
+	pairs := make([]string, 0, len(params))
+
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		value := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(params[key])
+		pairs = append(pairs, key+"='"+value+"'")
+	}
+
+	return strings.Join(pairs, " ")
+}
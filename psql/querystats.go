@@ -0,0 +1,196 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrQueryCallsExceeded = errors.New("number of query calls exceeds the limit")
+
+// Statistics of the normalized query collected by pg_stat_statements.
+type QueryStat struct {
+	// Fingerprint of the normalized query (queryid)
+	Fingerprint int64
+	// Text of the query in which constants are replaced by parameter symbols
+	Query     string
+	Calls     int64
+	Rows      int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+}
+
+// Statistics of the queries ordered by the number of calls in descending order.
+type QueryStats []QueryStat
+
+// Resets statistics of pg_stat_statements on the node to which the DSN points.
+// Extension must be enabled on the node, e.g. by the [WithPgStatStatements] option.
+//
+// Used at the start of a test section to take into account only the queries executed
+// in it.
+func ResetQueryStats(ctx context.Context, dsn url.URL) error {
+	db, err := openDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "SELECT pg_stat_statements_reset()")
+
+	return err
+}
+
+// Collects statistics of pg_stat_statements on the node to which the DSN points
+// for the queries executed in the database specified in the DSN since the last
+// reset. Queries that refer to pg_stat_statements are excluded. Extension must be
+// enabled on the node, e.g. by the [WithPgStatStatements] option.
+func CollectQueryStats(ctx context.Context, dsn url.URL) (QueryStats, error) {
+	db, err := openDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	// Queries to the pg_stat_statements, including this one and the reset, are
+	// excluded
+	statement := `
+		SELECT
+			coalesce(queryid, 0),
+			query,
+			calls,
+			rows,
+			total_exec_time,
+			mean_exec_time
+		FROM
+			pg_stat_statements
+		WHERE
+			dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND query NOT LIKE '%pg_stat_statements%'
+		ORDER BY
+			calls DESC, query
+	`
+
+	rows, err := db.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stats := make(QueryStats, 0)
+
+	for rows.Next() {
+		var (
+			stat      QueryStat
+			totalTime float64
+			meanTime  float64
+		)
+
+		err := rows.Scan(
+			&stat.Fingerprint,
+			&stat.Query,
+			&stat.Calls,
+			&stat.Rows,
+			&totalTime,
+			&meanTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		stat.TotalTime = millisecondsToDuration(totalTime)
+		stat.MeanTime = millisecondsToDuration(meanTime)
+
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// Returns statistics of the queries whose text matches the regular expression.
+func (stats QueryStats) Filter(pattern *regexp.Regexp) QueryStats {
+	filtered := make(QueryStats, 0)
+
+	for _, stat := range stats {
+		if pattern.MatchString(stat.Query) {
+			filtered = append(filtered, stat)
+		}
+	}
+
+	return filtered
+}
+
+// Returns total number of calls of the queries.
+func (stats QueryStats) Calls() int64 {
+	calls := int64(0)
+
+	for _, stat := range stats {
+		calls += stat.Calls
+	}
+
+	return calls
+}
+
+// Returns total number of rows retrieved or affected by the queries.
+func (stats QueryStats) Rows() int64 {
+	rows := int64(0)
+
+	for _, stat := range stats {
+		rows += stat.Rows
+	}
+
+	return rows
+}
+
+// Returns an error if the total number of calls of the queries whose text matches
+// the regular expression exceeds the limit. Text of the error contains the matched
+// queries with their number of calls.
+func (stats QueryStats) CheckMaxCalls(pattern string, limit int64) error {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	matched := stats.Filter(compiled)
+
+	if calls := matched.Calls(); calls > limit {
+		return fmt.Errorf(
+			"%w: %d > %d for pattern %q:\n%s",
+			ErrQueryCallsExceeded,
+			calls,
+			limit,
+			pattern,
+			matched,
+		)
+	}
+
+	return nil
+}
+
+func (stats QueryStats) String() string {
+	lines := make([]string, 0, len(stats))
+
+	for _, stat := range stats {
+		lines = append(lines, fmt.Sprintf("%d calls: %s", stat.Calls, stat.Query))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func openDSN(dsn url.URL) (*sql.DB, error) {
+	driver := sqlDriver(dsn.Scheme)
+	dsn.Scheme = "postgres"
+
+	return sql.Open(driver, dsn.String())
+}
+
+func millisecondsToDuration(milliseconds float64) time.Duration {
+	return time.Duration(milliseconds * float64(time.Millisecond))
+}
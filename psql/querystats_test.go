@@ -0,0 +1,78 @@
+package psql
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStats(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	dsns, cleanup, err := RunWith(t.Context(), "17", []string{"pgx5"}, WithPgStatStatements())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, cleanup(t.Context(), t))
+	}()
+
+	dsn := dsns[0]
+	dsn.Scheme = "postgres"
+
+	db, err := sql.Open("pgx", dsn.String())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE items (id integer)")
+	require.NoError(t, err)
+
+	require.NoError(t, ResetQueryStats(t.Context(), dsns[0]))
+
+	for id := range 5 {
+		_, err = db.ExecContext(t.Context(), "INSERT INTO items VALUES ($1)", id)
+		require.NoError(t, err)
+	}
+
+	stats, err := CollectQueryStats(t.Context(), dsns[0])
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, "INSERT INTO items VALUES ($1)", stats[0].Query)
+	require.Equal(t, int64(5), stats[0].Calls)
+	require.Equal(t, int64(5), stats[0].Rows)
+	require.NotZero(t, stats[0].Fingerprint)
+
+	require.NoError(t, stats.CheckMaxCalls("(?i)insert into items", 5))
+	require.ErrorIs(t, stats.CheckMaxCalls("(?i)insert into items", 4), ErrQueryCallsExceeded)
+}
+
+func TestQueryStatsMethods(t *testing.T) {
+	stats := QueryStats{
+		{Query: "SELECT * FROM users WHERE id = $1", Calls: 10, Rows: 10},
+		{Query: "SELECT * FROM orders WHERE user_id = $1", Calls: 3, Rows: 7},
+		{Query: "UPDATE users SET name = $1", Calls: 1, Rows: 1},
+	}
+
+	require.Equal(t, int64(14), stats.Calls())
+	require.Equal(t, int64(18), stats.Rows())
+
+	selects := stats.Filter(regexp.MustCompile("^SELECT"))
+	require.Len(t, selects, 2)
+	require.Equal(t, int64(13), selects.Calls())
+
+	require.NoError(t, stats.CheckMaxCalls("users", 11))
+	require.ErrorIs(t, stats.CheckMaxCalls("users", 10), ErrQueryCallsExceeded)
+	require.NoError(t, stats.CheckMaxCalls("unknown", 0))
+	require.Error(t, stats.CheckMaxCalls("(", 0))
+}
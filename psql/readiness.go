@@ -0,0 +1,122 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	_ "github.com/jackc/pgx/v5/stdlib" // Used to check readiness of the nodes
+	_ "github.com/lib/pq"              // Used to check readiness of the nodes
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// On the first start the image runs a temporary server to initialize the data
+	// directory, which also logs that it is ready to accept connections, and then
+	// restarts it. So readiness is determined by a message logged after
+	// initialization is complete or skipped.
+	readyLogPattern = `(?s)(?:PostgreSQL init process complete|Skipping initialization)` +
+		`.*ready to accept connections`
+
+	// Standbys are initialized from a base backup of the primary, so the image skips
+	// initialization and the server accepts only read-only connections.
+	standbyReadyLogPattern = `(?s)Skipping initialization.*ready to accept read-only connections`
+
+	sqlDriverPgx = "pgx"
+	sqlDriverPq  = "postgres"
+)
+
+// Allows to use a function as a wait strategy.
+type waitFunc func(ctx context.Context, target wait.StrategyTarget) error
+
+func (fn waitFunc) WaitUntilReady(ctx context.Context, target wait.StrategyTarget) error {
+	return fn(ctx, target)
+}
+
+func (grp *Group) prepareWaiting(id int, node *node) wait.Strategy {
+	if node.primary != nil {
+		return grp.prepareStandbyWaiting(node)
+	}
+
+	strategies := []wait.Strategy{
+		wait.ForLog(readyLogPattern).AsRegexp(),
+	}
+
+	if grp.opts.tmpfs {
+		strategies = append(strategies, waitFunc(node.disableDurability))
+	}
+
+	if grp.opts.volumesPrefix != "" {
+		strategies = append(strategies, node.resetPassword(grp.volumeName(id)))
+	}
+
+	if len(grp.opts.extensions) != 0 {
+		strategies = append(strategies, node.createExtensions(grp.opts.extensions))
+	}
+
+	if len(grp.opts.roles) != 0 {
+		strategies = append(strategies, node.createRoles(grp.opts.roles))
+	}
+
+	strategies = append(strategies, grp.prepareSQLWaiting(node))
+
+	return wait.ForAll(strategies...).
+		WithStartupTimeoutDefault(grp.opts.startupTimeout).
+		WithDeadline(grp.opts.startupTimeout)
+}
+
+// Roles, extensions, settings and the password are copied from the primary, so the
+// standby is only checked for readiness.
+func (grp *Group) prepareStandbyWaiting(node *node) wait.Strategy {
+	strategies := []wait.Strategy{
+		wait.ForLog(standbyReadyLogPattern).AsRegexp(),
+		grp.prepareSQLWaiting(node),
+	}
+
+	return wait.ForAll(strategies...).
+		WithStartupTimeoutDefault(grp.opts.startupTimeout).
+		WithDeadline(grp.opts.startupTimeout)
+}
+
+func (grp *Group) prepareSQLWaiting(node *node) wait.Strategy {
+	dsn := func(host string, port nat.Port) string {
+		dsn := grp.prepareDSN(node, net.JoinHostPort(host, port.Port()))
+		dsn.Scheme = "postgres"
+
+		return dsn.String()
+	}
+
+	return wait.ForSQL(sqlPortTCP, sqlDriver(node.driver), dsn)
+}
+
+// Data directory in a persistent volume can be initialized in a previous run
+// with a different password, in which case the POSTGRES_PASSWORD environment
+// variable is ignored by the image. The superuser is ignored as well, so the data
+// directory initialized with another one is reported instead of a failed query.
+func (n *node) resetPassword(volume string) waitFunc {
+	reset := func(ctx context.Context, target wait.StrategyTarget) error {
+		statement := "ALTER ROLE " + quoteIdentifier(n.user) +
+			" PASSWORD " + quoteLiteral(n.password)
+
+		_, err := query(ctx, target, n.user, maintenanceDatabase, statement)
+		if err != nil && strings.Contains(err.Error(), `role "`+n.user+`" does not exist`) {
+			return fmt.Errorf("%w: volume %s, user %s", ErrVolumeUserMismatch, volume, n.user)
+		}
+
+		return err
+	}
+
+	return reset
+}
+
+// Returns name of the database/sql driver that corresponds to the driver specified
+// as a DSN scheme.
+func sqlDriver(driver string) string {
+	if strings.HasPrefix(driver, sqlDriverPgx) {
+		return sqlDriverPgx
+	}
+
+	return sqlDriverPq
+}
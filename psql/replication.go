@@ -0,0 +1,194 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output plugins of logical decoding.
+const (
+	PluginPgoutput     = "pgoutput"
+	PluginTestDecoding = "test_decoding"
+)
+
+const slotLagPollInterval = 100 * time.Millisecond
+
+var (
+	ErrPublicationNameEmpty  = errors.New("publication name is empty")
+	ErrSlotNameEmpty         = errors.New("replication slot name is empty")
+	ErrSlotNotFound          = errors.New("replication slot was not found")
+	ErrSlotNotConfirmed      = errors.New("replication slot has no confirmed position")
+	ErrSubscriptionNameEmpty = errors.New("subscription name is empty")
+)
+
+// Starts the nodes with wal_level=logical, which is required for publications and
+// logical replication slots.
+func WithLogicalReplication() Adjuster {
+	adj := func(opts *options) error {
+		opts.logicalReplication = true
+		return nil
+	}
+
+	return adj
+}
+
+// Creates a publication in the database of the group on the node with the specified
+// index. If tables are not specified, the publication is created for all tables.
+func (grp *Group) CreatePublication(ctx context.Context, id int, name string, tables ...string) error {
+	if name == "" {
+		return ErrPublicationNameEmpty
+	}
+
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	statement := "CREATE PUBLICATION " + quoteIdentifier(name) + " FOR ALL TABLES"
+
+	if len(tables) != 0 {
+		quoted := make([]string, len(tables))
+
+		for id, table := range tables {
+			quoted[id] = quoteQualifiedIdentifier(table)
+		}
+
+		statement = "CREATE PUBLICATION " + quoteIdentifier(name) +
+			" FOR TABLE " + strings.Join(quoted, ", ")
+	}
+
+	if _, err := node.query(ctx, node.database, statement); err != nil {
+		return fmt.Errorf("creating publication: %w", err)
+	}
+
+	return nil
+}
+
+// Creates a logical replication slot in the database of the group on the node with
+// the specified index using specified output plugin, e.g. [PluginPgoutput] or
+// [PluginTestDecoding].
+func (grp *Group) CreateReplicationSlot(ctx context.Context, id int, name string, plugin string) error {
+	if name == "" {
+		return ErrSlotNameEmpty
+	}
+
+	node, err := grp.node(id)
+	if err != nil {
+		return err
+	}
+
+	statement := "SELECT pg_create_logical_replication_slot(" +
+		quoteLiteral(name) + ", " + quoteLiteral(plugin) + ")"
+
+	if _, err := node.query(ctx, node.database, statement); err != nil {
+		return fmt.Errorf("creating replication slot: %w", err)
+	}
+
+	return nil
+}
+
+// Creates a subscription on the subscriber node to the publication on the
+// publisher node. Tables of the publication must exist in the database of the group
+// on the subscriber node. A replication slot with the name of the subscription is
+// created on the publisher node.
+func (grp *Group) Subscribe(
+	ctx context.Context,
+	publisherID int,
+	subscriberID int,
+	subscription string,
+	publication string,
+) error {
+	if subscription == "" {
+		return ErrSubscriptionNameEmpty
+	}
+
+	if publication == "" {
+		return ErrPublicationNameEmpty
+	}
+
+	publisher, err := grp.node(publisherID)
+	if err != nil {
+		return err
+	}
+
+	subscriber, err := grp.node(subscriberID)
+	if err != nil {
+		return err
+	}
+
+	statement := "CREATE SUBSCRIPTION " + quoteIdentifier(subscription) +
+		" CONNECTION " + quoteLiteral(grp.prepareConnString(publisher, publisher.database)) +
+		" PUBLICATION " + quoteIdentifier(publication)
+
+	if _, err := subscriber.query(ctx, subscriber.database, statement); err != nil {
+		return fmt.Errorf("creating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Returns lag in bytes of the logical replication slot on the node with the
+// specified index, i.e. amount of WAL between the current WAL position and the
+// position confirmed by the consumer of the slot.
+//
+// If the consumer has not confirmed any position yet, [ErrSlotNotConfirmed] is
+// returned.
+func (grp *Group) SlotLag(ctx context.Context, id int, slot string) (int64, error) {
+	node, err := grp.node(id)
+	if err != nil {
+		return 0, err
+	}
+
+	// NULL is printed as an empty string, as well as the absence of rows, so the
+	// unconfirmed position is reported explicitly
+	statement := "SELECT confirmed_flush_lsn IS NULL, " +
+		"coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn)::bigint, 0) " +
+		"FROM pg_replication_slots WHERE slot_name = " + quoteLiteral(slot)
+
+	output, err := node.query(ctx, maintenanceDatabase, statement)
+	if err != nil {
+		return 0, fmt.Errorf("querying slot lag: %w", err)
+	}
+
+	if output == "" {
+		return 0, fmt.Errorf("%w: %s", ErrSlotNotFound, slot)
+	}
+
+	unconfirmed, lag, _ := strings.Cut(output, "|")
+
+	if unconfirmed == "t" {
+		return 0, fmt.Errorf("%w: %s", ErrSlotNotConfirmed, slot)
+	}
+
+	return strconv.ParseInt(lag, 10, 64)
+}
+
+// Waits until the lag of the logical replication slot on the node with the
+// specified index becomes less than or equal to the specified value. Slot without
+// a confirmed position is considered as not caught up. Waiting is limited by the
+// context.
+func (grp *Group) WaitSlotLag(ctx context.Context, id int, slot string, lag int64) error {
+	ticker := time.NewTicker(slotLagPollInterval)
+	defer ticker.Stop()
+
+	for {
+		current, err := grp.SlotLag(ctx, id, slot)
+		if err != nil && !errors.Is(err, ErrSlotNotConfirmed) {
+			return err
+		}
+
+		if err == nil && current <= lag {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
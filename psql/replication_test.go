@@ -0,0 +1,107 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogicalReplication(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithLogicalReplication(),
+		WithClientCertAuth(),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dbs := make([]*sql.DB, len(grp.DSNs()))
+
+	for id, dsn := range grp.DSNs() {
+		dsn.Scheme = "postgres"
+
+		db, err := sql.Open("pgx", dsn.String())
+		require.NoError(t, err)
+
+		defer db.Close()
+
+		_, err = db.ExecContext(t.Context(), "CREATE TABLE items (id bigint PRIMARY KEY)")
+		require.NoError(t, err)
+
+		dbs[id] = db
+	}
+
+	require.NoError(t, grp.CreatePublication(t.Context(), 0, "items", "public.items"))
+	require.NoError(t, grp.Subscribe(t.Context(), 0, 1, "items", "items"))
+	require.NoError(t, grp.CreateReplicationSlot(t.Context(), 0, "decoding", PluginTestDecoding))
+
+	_, err = dbs[0].ExecContext(t.Context(), "INSERT INTO items SELECT generate_series(1, 100)")
+	require.NoError(t, err)
+
+	lag, err := grp.SlotLag(t.Context(), 0, "decoding")
+	require.NoError(t, err)
+	require.Positive(t, lag)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+
+	require.NoError(t, grp.WaitSlotLag(ctx, 0, "items", 0))
+
+	var count int
+
+	require.NoError(t, dbs[1].QueryRowContext(t.Context(), "SELECT count(*) FROM items").Scan(&count))
+	require.Equal(t, 100, count)
+
+	_, err = grp.SlotLag(t.Context(), 0, "unknown")
+	require.ErrorIs(t, err, ErrSlotNotFound)
+
+	// Physical slots never have a confirmed position
+	_, err = dbs[0].ExecContext(t.Context(), "SELECT pg_create_physical_replication_slot('physical')")
+	require.NoError(t, err)
+
+	_, err = grp.SlotLag(t.Context(), 0, "physical")
+	require.ErrorIs(t, err, ErrSlotNotConfirmed)
+
+	ctx, cancel = context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	require.ErrorIs(t, grp.WaitSlotLag(ctx, 0, "physical", 0), context.DeadlineExceeded)
+
+	require.ErrorIs(t, grp.CreatePublication(t.Context(), 2, "items"), ErrNodeNotFound)
+	require.ErrorIs(t, grp.Subscribe(t.Context(), 0, -1, "items", "items"), ErrNodeNotFound)
+}
+
+func TestPrepareConnString(t *testing.T) {
+	params := map[string]string{
+		"host":     "node",
+		"password": `it's\`,
+		"sslmode":  "disable",
+	}
+
+	require.Equal(t,
+		`host='node' password='it\'s\\' sslmode='disable'`,
+		prepareConnString(params),
+	)
+}
+
+func TestQuoteQualifiedIdentifier(t *testing.T) {
+	require.Equal(t, `"items"`, quoteQualifiedIdentifier("items"))
+	require.Equal(t, `"public"."it""ems"`, quoteQualifiedIdentifier(`public.it"ems`))
+}
@@ -0,0 +1,129 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/akramarenkov/illusion/internal/hostconfig"
+	"github.com/akramarenkov/illusion/internal/resources"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+var (
+	ErrCPUsNegative        = resources.ErrCPUsNegative
+	ErrMemoryNegative      = resources.ErrMemoryNegative
+	ErrSwapWithoutMemory   = resources.ErrSwapWithoutMemory
+	ErrPidsLimitNegative   = resources.ErrPidsLimitNegative
+	ErrCPUsNotPositive     = resources.ErrCPUsNotPositive
+	ErrCPUsNotChanged      = errors.New("number of CPUs of node was not changed")
+	ErrNodeOOMKilled       = resources.ErrNodeOOMKilled
+	ErrOOMKilledNotChecked = resources.ErrOOMKilledNotChecked
+)
+
+// Resource limits of a node. Zero value of a limit means no limit.
+type Resources = resources.Resources
+
+// Node was killed by the kernel due to exceeding the memory limit.
+type OOMKilledError = resources.OOMKilledError
+
+// Limits resources of the nodes with the specified indices. If indices are not
+// specified, limits are used for all nodes for which they are not specified
+// individually.
+//
+// Node killed due to exceeding the memory limit during the start of the group is
+// reported by the [OOMKilledError] error.
+func WithResources(res Resources, nodes ...int) Adjuster {
+	adj := func(opts *options) error {
+		if err := resources.Validate(res); err != nil {
+			return err
+		}
+
+		if len(nodes) == 0 {
+			opts.resources = &res
+			return nil
+		}
+
+		if opts.nodeResources == nil {
+			opts.nodeResources = make(map[int]Resources, len(nodes))
+		}
+
+		for _, id := range nodes {
+			if id < 0 {
+				return fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+			}
+
+			opts.nodeResources[id] = res
+		}
+
+		return nil
+	}
+
+	return adj
+}
+
+func (opts options) resourcesOfNode(id int) *Resources {
+	if res, exists := opts.nodeResources[id]; exists {
+		return &res
+	}
+
+	return opts.resources
+}
+
+func (grp *Group) prepareNodeResources(id int, req *testcontainers.GenericContainerRequest) {
+	res := grp.opts.resourcesOfNode(id)
+	if res == nil {
+		return
+	}
+
+	hostconfig.Add(req, func(config *container.HostConfig) {
+		resources.Apply(*res, config)
+	})
+}
+
+// Returns [OOMKilledError] error if any node of the group was killed due to
+// exceeding the memory limit.
+func (grp *Group) CheckOOMKilled(ctx context.Context) error {
+	return grp.checkOOMKilled(ctx, nil)
+}
+
+// Replaces the error caused by the node killed due to out of memory with the
+// [OOMKilledError] error.
+func (grp *Group) checkOOMKilled(ctx context.Context, cause error) error {
+	return resources.CheckOOMKilled(ctx, grp.nodes, cause)
+}
+
+// Changes the number of CPUs available to the running node, e.g. to turn it into a
+// straggler. Number may be fractional, e.g. 0.1. Previous number is restored by
+// the [Group.RestoreCPUs] method.
+func (grp *Group) SetCPUs(ctx context.Context, id int, number float64) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCPUsNotChanged, err)
+	}
+
+	if err := resources.SetCPUs(ctx, node.container.GetContainerID(), number); err != nil {
+		return fmt.Errorf("%w: %w", ErrCPUsNotChanged, err)
+	}
+
+	return nil
+}
+
+// Restores the number of CPUs available to the node to the one specified by the
+// [WithResources] option or removes the limit if it was not specified.
+func (grp *Group) RestoreCPUs(ctx context.Context, id int) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCPUsNotChanged, err)
+	}
+
+	res := grp.opts.resourcesOfNode(id)
+
+	if err := resources.RestoreCPUs(ctx, node.container.GetContainerID(), res); err != nil {
+		return fmt.Errorf("%w: %w", ErrCPUsNotChanged, err)
+	}
+
+	return nil
+}
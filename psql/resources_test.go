@@ -0,0 +1,128 @@
+package psql
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	res := Resources{
+		CPUs:      0.5,
+		Memory:    256 << 20,
+		Swap:      64 << 20,
+		PidsLimit: 200,
+	}
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithResources(res),
+		WithResources(Resources{Memory: 512 << 20}, 1),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	first, err := grp.nodes[0].container.Inspect(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, int64(500_000_000), first.HostConfig.NanoCPUs)
+	require.Equal(t, int64(256<<20), first.HostConfig.Memory)
+	require.Equal(t, int64(320<<20), first.HostConfig.MemorySwap)
+	require.Equal(t, int64(200), *first.HostConfig.PidsLimit)
+
+	second, err := grp.nodes[1].container.Inspect(t.Context())
+	require.NoError(t, err)
+
+	require.Zero(t, second.HostConfig.NanoCPUs)
+	require.Equal(t, int64(512<<20), second.HostConfig.Memory)
+
+	require.NoError(t, grp.CheckOOMKilled(t.Context()))
+
+	nanoCPUs := func(id int) int64 {
+		info, err := grp.nodes[id].container.Inspect(t.Context())
+		require.NoError(t, err)
+
+		return info.HostConfig.NanoCPUs
+	}
+
+	require.NoError(t, grp.SetCPUs(t.Context(), 0, 0.1))
+	require.Equal(t, int64(100_000_000), nanoCPUs(0))
+	require.NoError(t, grp.RestoreCPUs(t.Context(), 0))
+	require.Equal(t, int64(500_000_000), nanoCPUs(0))
+
+	require.NoError(t, grp.SetCPUs(t.Context(), 1, 0.2))
+	require.Equal(t, int64(200_000_000), nanoCPUs(1))
+	require.NoError(t, grp.RestoreCPUs(t.Context(), 1))
+	require.Greater(t, nanoCPUs(1), int64(200_000_000))
+
+	require.ErrorIs(t, grp.SetCPUs(t.Context(), 0, 0), ErrCPUsNotPositive)
+	require.ErrorIs(t, grp.SetCPUs(t.Context(), 2, 1), ErrNodeNotFound)
+	require.ErrorIs(t, grp.RestoreCPUs(t.Context(), -1), ErrNodeNotFound)
+}
+
+func TestResourcesOOMKilled(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5"},
+		WithResources(Resources{Memory: 6 << 20}),
+		WithStartupTimeout(30*time.Second),
+	)
+	require.ErrorIs(t, err, ErrNodeOOMKilled)
+	require.Nil(t, grp)
+
+	var oomErr *OOMKilledError
+
+	require.ErrorAs(t, err, &oomErr)
+	require.Equal(t, 0, oomErr.Node)
+}
+
+func TestResourcesOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithResources(Resources{CPUs: -1})(&opts), ErrCPUsNegative)
+	require.ErrorIs(t, WithResources(Resources{Memory: -1})(&opts), ErrMemoryNegative)
+	require.ErrorIs(t, WithResources(Resources{Swap: 1})(&opts), ErrSwapWithoutMemory)
+	require.ErrorIs(t, WithResources(Resources{PidsLimit: -1})(&opts), ErrPidsLimitNegative)
+	require.ErrorIs(t, WithResources(Resources{}, -1)(&opts), ErrNodeNotFound)
+
+	require.Nil(t, opts.resourcesOfNode(0))
+
+	require.NoError(t, WithResources(Resources{CPUSet: "0", Memory: 1 << 20, Swap: -1})(&opts))
+	require.NoError(t, WithResources(Resources{CPUs: 2}, 1)(&opts))
+
+	require.Equal(t, &Resources{CPUSet: "0", Memory: 1 << 20, Swap: -1}, opts.resourcesOfNode(0))
+	require.Equal(t, &Resources{CPUs: 2}, opts.resourcesOfNode(1))
+}
+
+func TestCheckOOMKilled(t *testing.T) {
+	cause := errors.New("readiness timeout")
+
+	grp := &Group{nodes: []*node{{}}}
+
+	require.NoError(t, grp.CheckOOMKilled(t.Context()))
+	require.ErrorIs(t, grp.checkOOMKilled(t.Context(), cause), cause)
+}
@@ -0,0 +1,98 @@
+package psql
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Additional role created on each node of the group.
+type Role struct {
+	// Name of the role. Required parameter
+	Name string
+
+	// Password of the role. If not specified, the role is created without the
+	// LOGIN attribute
+	Password string
+
+	// Privileges granted to the role in the database of the group, each in the
+	// form of the GRANT statement without the TO clause, e.g.
+	// "ALL ON SCHEMA public" or "pg_read_all_data"
+	Grants []string
+}
+
+func (role Role) statements() []string {
+	name := quoteIdentifier(role.Name)
+
+	statements := []string{
+		"DO $$ BEGIN IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = " +
+			quoteLiteral(role.Name) + ") THEN CREATE ROLE " + name + "; END IF; END $$",
+	}
+
+	if role.Password == "" {
+		statements = append(statements, "ALTER ROLE "+name+" NOLOGIN PASSWORD NULL")
+	} else {
+		statements = append(
+			statements,
+			"ALTER ROLE "+name+" LOGIN PASSWORD "+quoteLiteral(role.Password),
+		)
+	}
+
+	for _, grant := range role.Grants {
+		statements = append(statements, "GRANT "+strings.TrimSpace(grant)+" TO "+name)
+	}
+
+	return statements
+}
+
+// Roles are created after the node is started rather than by the initialization
+// scripts of the image, so they are also created or updated in the data directory
+// initialized in a previous run.
+func (n *node) createRoles(roles []Role) waitFunc {
+	create := func(ctx context.Context, target wait.StrategyTarget) error {
+		for _, role := range roles {
+			if _, err := query(ctx, target, n.user, n.database, role.statements()...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return create
+}
+
+// Returns DSNs of the nodes for connecting as the role with the specified name
+// created by the [WithRoles] option. Role must have a password. With the
+// [WithClientCertAuth] option DSNs refer to the client certificate issued for the
+// role.
+func (grp *Group) RoleDSNs(name string) ([]url.URL, error) {
+	// Role specified later overrides the previous one with the same name
+	id := len(grp.opts.roles) - 1
+
+	for id >= 0 && grp.opts.roles[id].Name != name {
+		id--
+	}
+
+	if id < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+	}
+
+	role := grp.opts.roles[id]
+
+	if role.Password == "" {
+		return nil, fmt.Errorf("%w: %s", ErrRoleNotLogin, name)
+	}
+
+	dsns := grp.DSNs()
+
+	for id := range dsns {
+		dsns[id].User = url.UserPassword(role.Name, role.Password)
+		dsns[id].RawQuery = grp.tlsRoleQuery(role.Name).Encode()
+	}
+
+	return dsns, nil
+}
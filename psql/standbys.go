@@ -0,0 +1,361 @@
+package psql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Methods of choosing synchronous standbys among the listed ones.
+type SyncMethod string
+
+const (
+	// Priority-based: the first standbys in the list are synchronous
+	SyncFirst SyncMethod = "FIRST"
+	// Quorum-based: any standbys from the list can confirm a commit
+	SyncAny SyncMethod = "ANY"
+)
+
+// Values of the synchronous_commit setting.
+const (
+	SyncCommitOff         = "off"
+	SyncCommitLocal       = "local"
+	SyncCommitRemoteWrite = "remote_write"
+	SyncCommitOn          = "on"
+	SyncCommitRemoteApply = "remote_apply"
+)
+
+const (
+	// Allows standbys to connect to the primary for physical replication when the
+	// pg_hba.conf generated by the image is used
+	replicationInitFile = "/docker-entrypoint-initdb.d/illusion-replication.sh"
+
+	lsnPollInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrStandbysQuantityZero = errors.New("standbys quantity is zero")
+	ErrNodeNotStandby       = errors.New("node is not a standby")
+	ErrSyncMethodInvalid    = errors.New("synchronous standbys method is invalid")
+	ErrSyncQuantityInvalid  = errors.New("synchronous standbys quantity is out of range")
+	ErrSyncCommitInvalid    = errors.New("synchronous commit level is invalid")
+	ErrApplyDelayNegative   = errors.New("apply delay is negative")
+	ErrStreamingDisabled    = errors.New("streaming replication is not enabled")
+)
+
+// Replication state of the standby as seen by the primary.
+type ReplicationLag struct {
+	// Amount of WAL in bytes that is not yet replayed on the standby
+	Bytes int64
+	// Time elapsed between flushing recent WAL locally and receiving notification
+	// that the standby has written, flushed and applied it (replay_lag)
+	Replay time.Duration
+	// Synchronous state of the standby: async, potential, sync or quorum
+	SyncState string
+}
+
+// Makes the first node of the group a primary and the others its streaming
+// standbys. Standbys are created from a base backup of the primary taken by
+// pg_basebackup and are started after the primary is ready. Returned DSNs of the
+// standbys allow only read-only transactions.
+//
+// By default replication is asynchronous, the mode can be changed on a running
+// group by the [Group.SetSynchronousStandbys] and [Group.SetSynchronousCommit]
+// methods.
+func WithStreamingReplication() Adjuster {
+	adj := func(opts *options) error {
+		opts.streamingReplication = true
+		return nil
+	}
+
+	return adj
+}
+
+func (grp *Group) prepareStandbys() {
+	if !grp.opts.streamingReplication {
+		return
+	}
+
+	primary := grp.nodes[0]
+
+	for _, standby := range grp.nodes[1:] {
+		// Roles are copied from the primary along with the data
+		standby.password = primary.password
+		standby.primary = primary
+	}
+}
+
+func (grp *Group) prepareNodeReplication(node *node, req *testcontainers.GenericContainerRequest) {
+	if !grp.opts.streamingReplication || node.primary != nil || grp.tls != nil {
+		return
+	}
+
+	script := `echo "host replication all all scram-sha-256" >> "$PGDATA/` + hbaFile + `"` + "\n"
+
+	file := testcontainers.ContainerFile{
+		Reader:            bytes.NewReader([]byte(script)),
+		ContainerFilePath: replicationInitFile,
+		FileMode:          certsFileMode,
+	}
+
+	req.Files = append(req.Files, file)
+}
+
+func (grp *Group) standbySteps(node *node) []string {
+	if node.primary == nil {
+		return nil
+	}
+
+	backup := "gosu postgres pg_basebackup" +
+		" --dbname " + quoteShell(grp.primaryConnInfo(node)) +
+		` --pgdata "$PGDATA" --checkpoint fast --wal-method stream`
+
+	// Data directory is filled only once, so the standby can be restarted
+	steps := []string{
+		`if [ ! -s "$PGDATA/PG_VERSION" ]; then ` +
+			`install -d -o postgres -g postgres -m 0700 "$PGDATA" && ` +
+			backup + ` && touch "$PGDATA/standby.signal"; fi`,
+	}
+
+	return steps
+}
+
+func (grp *Group) standbySettings(node *node, settings map[string]string) {
+	if node.primary == nil {
+		return
+	}
+
+	settings["primary_conninfo"] = grp.primaryConnInfo(node)
+}
+
+// Standbys are identified on the primary by their hostnames used as the
+// application name.
+func (grp *Group) primaryConnInfo(standby *node) string {
+	params := grp.tlsConnParams()
+
+	params["host"] = standby.primary.hostname
+	params["port"] = sqlPort
+	params["user"] = standby.primary.user
+	params["password"] = standby.primary.password
+	params["application_name"] = standby.hostname
+
+	return prepareConnString(params)
+}
+
+func (grp *Group) primary() (*node, error) {
+	if !grp.opts.streamingReplication {
+		return nil, ErrStreamingDisabled
+	}
+
+	return grp.nodes[0], nil
+}
+
+func (grp *Group) standby(id int) (*node, error) {
+	node, err := grp.node(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.primary == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotStandby, id)
+	}
+
+	return node, nil
+}
+
+// Sets synchronous_standby_names on the primary to the standbys with the specified
+// indices using the specified method and number of synchronous standbys. If
+// standbys are not specified, replication becomes asynchronous.
+func (grp *Group) SetSynchronousStandbys(
+	ctx context.Context,
+	method SyncMethod,
+	quantity int,
+	standbys ...int,
+) error {
+	primary, err := grp.primary()
+	if err != nil {
+		return err
+	}
+
+	if len(standbys) == 0 {
+		return primary.setSetting(ctx, "synchronous_standby_names", "")
+	}
+
+	if method != SyncFirst && method != SyncAny {
+		return fmt.Errorf("%w: %s", ErrSyncMethodInvalid, method)
+	}
+
+	if quantity <= 0 || quantity > len(standbys) {
+		return fmt.Errorf("%w: %d", ErrSyncQuantityInvalid, quantity)
+	}
+
+	names := make([]string, 0, len(standbys))
+
+	for _, id := range standbys {
+		standby, err := grp.standby(id)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, quoteIdentifier(standby.hostname))
+	}
+
+	value := string(method) + " " + strconv.Itoa(quantity) + " (" + strings.Join(names, ", ") + ")"
+
+	return primary.setSetting(ctx, "synchronous_standby_names", value)
+}
+
+// Sets synchronous_commit on the primary to the specified level, e.g.
+// [SyncCommitRemoteApply].
+func (grp *Group) SetSynchronousCommit(ctx context.Context, level string) error {
+	primary, err := grp.primary()
+	if err != nil {
+		return err
+	}
+
+	switch level {
+	case SyncCommitOff, SyncCommitLocal, SyncCommitRemoteWrite, SyncCommitOn, SyncCommitRemoteApply:
+	default:
+		return fmt.Errorf("%w: %s", ErrSyncCommitInvalid, level)
+	}
+
+	return primary.setSetting(ctx, "synchronous_commit", level)
+}
+
+// Sets recovery_min_apply_delay on the standby with the specified index, so it
+// replays the WAL with the specified delay. Zero delay turns the delay off.
+func (grp *Group) SetApplyDelay(ctx context.Context, id int, delay time.Duration) error {
+	if delay < 0 {
+		return ErrApplyDelayNegative
+	}
+
+	standby, err := grp.standby(id)
+	if err != nil {
+		return err
+	}
+
+	value := strconv.FormatInt(delay.Milliseconds(), 10) + "ms"
+
+	return standby.setSetting(ctx, "recovery_min_apply_delay", value)
+}
+
+// Returns replication state of the connected standbys keyed by their indices.
+func (grp *Group) ReplicationLag(ctx context.Context) (map[int]ReplicationLag, error) {
+	primary, err := grp.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	statement := "SELECT application_name, " +
+		"coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::bigint, " +
+		"coalesce(extract(epoch FROM replay_lag), 0) * 1000000, " +
+		"sync_state FROM pg_stat_replication"
+
+	output, err := primary.query(ctx, maintenanceDatabase, statement)
+	if err != nil {
+		return nil, fmt.Errorf("querying replication lag: %w", err)
+	}
+
+	lags := make(map[int]ReplicationLag)
+
+	for line := range strings.Lines(output) {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 4 { //nolint:mnd // Number of selected columns
+			continue
+		}
+
+		id := grp.standbyIndex(fields[0])
+		if id < 0 {
+			continue
+		}
+
+		lag, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		replay, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		lags[id] = ReplicationLag{
+			Bytes:     lag,
+			Replay:    time.Duration(replay) * time.Microsecond,
+			SyncState: fields[3],
+		}
+	}
+
+	return lags, nil
+}
+
+// Returns current write-ahead log location on the primary. Can be used as an
+// argument of the [Group.WaitLSN] method.
+func (grp *Group) CurrentLSN(ctx context.Context) (string, error) {
+	primary, err := grp.primary()
+	if err != nil {
+		return "", err
+	}
+
+	return primary.query(ctx, maintenanceDatabase, "SELECT pg_current_wal_lsn()")
+}
+
+// Waits until the standby with the specified index replays the write-ahead log up
+// to the specified location. Waiting is limited by the context.
+func (grp *Group) WaitLSN(ctx context.Context, id int, lsn string) error {
+	standby, err := grp.standby(id)
+	if err != nil {
+		return err
+	}
+
+	statement := "SELECT coalesce(pg_last_wal_replay_lsn() >= " +
+		quoteLiteral(lsn) + "::pg_lsn, false)"
+
+	ticker := time.NewTicker(lsnPollInterval)
+	defer ticker.Stop()
+
+	for {
+		replayed, err := standby.query(ctx, maintenanceDatabase, statement)
+		if err != nil {
+			return err
+		}
+
+		if replayed == "t" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (grp *Group) standbyIndex(hostname string) int {
+	for id, node := range grp.nodes {
+		if node.primary != nil && node.hostname == hostname {
+			return id
+		}
+	}
+
+	return -1
+}
+
+// Changes the setting in the postgresql.auto.conf and reloads the configuration.
+func (n *node) setSetting(ctx context.Context, name string, value string) error {
+	statements := []string{
+		"ALTER SYSTEM SET " + name + " = " + quoteLiteral(value),
+		"SELECT pg_reload_conf()",
+	}
+
+	_, err := n.query(ctx, maintenanceDatabase, statements...)
+
+	return err
+}
@@ -0,0 +1,238 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingReplication(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5", "postgres"},
+		WithStreamingReplication(),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dbs := make([]*sql.DB, len(grp.DSNs()))
+
+	for id, dsn := range grp.DSNs() {
+		driver := sqlDriver(dsn.Scheme)
+		dsn.Scheme = "postgres"
+
+		dbs[id], err = sql.Open(driver, dsn.String())
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, dbs[id].Close())
+		}()
+	}
+
+	require.NoError(t, grp.SetSynchronousStandbys(t.Context(), SyncFirst, 1, 1))
+	require.NoError(t, grp.SetSynchronousCommit(t.Context(), SyncCommitRemoteApply))
+
+	_, err = dbs[0].ExecContext(t.Context(), "CREATE TABLE events (id integer)")
+	require.NoError(t, err)
+
+	_, err = dbs[0].ExecContext(t.Context(), "INSERT INTO events VALUES (1)")
+	require.NoError(t, err)
+
+	var count int
+
+	// Read-after-write is guaranteed on the synchronous standby
+	require.NoError(t, dbs[1].QueryRowContext(t.Context(), "SELECT count(*) FROM events").Scan(&count))
+	require.Equal(t, 1, count)
+
+	lags, err := grp.ReplicationLag(t.Context())
+	require.NoError(t, err)
+	require.Len(t, lags, 2)
+	require.Equal(t, "sync", lags[1].SyncState)
+	require.Equal(t, "async", lags[2].SyncState)
+
+	require.NoError(t, grp.SetSynchronousStandbys(t.Context(), SyncFirst, 0))
+	require.NoError(t, grp.SetApplyDelay(t.Context(), 2, time.Hour))
+
+	_, err = dbs[0].ExecContext(t.Context(), "INSERT INTO events VALUES (2)")
+	require.NoError(t, err)
+
+	lsn, err := grp.CurrentLSN(t.Context())
+	require.NoError(t, err)
+
+	require.NoError(t, grp.WaitLSN(t.Context(), 1, lsn))
+
+	require.NoError(t, dbs[1].QueryRowContext(t.Context(), "SELECT count(*) FROM events").Scan(&count))
+	require.Equal(t, 2, count)
+
+	delayed, cancel := context.WithTimeout(t.Context(), 3*time.Second)
+	defer cancel()
+
+	require.ErrorIs(t, grp.WaitLSN(delayed, 2, lsn), context.DeadlineExceeded)
+
+	require.NoError(t, grp.SetApplyDelay(t.Context(), 2, 0))
+	require.NoError(t, grp.WaitLSN(t.Context(), 2, lsn))
+
+	_, err = dbs[1].ExecContext(t.Context(), "INSERT INTO events VALUES (3)")
+	require.Error(t, err)
+}
+
+func TestStreamingReplicationTmpfs(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5", "pgx5"},
+		WithStreamingReplication(),
+		WithTmpfs(),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	dbs := make([]*sql.DB, len(grp.DSNs()))
+
+	for id, dsn := range grp.DSNs() {
+		dbs[id], err = openDSN(dsn)
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, dbs[id].Close())
+		}()
+	}
+
+	show := func(db *sql.DB, name string) string {
+		var value string
+
+		require.NoError(t, db.QueryRowContext(t.Context(), "SHOW "+name).Scan(&value))
+
+		return value
+	}
+
+	for _, db := range dbs {
+		require.Equal(t, "off", show(db, "fsync"))
+		require.Equal(t, "off", show(db, "synchronous_commit"))
+	}
+
+	require.NoError(t, grp.SetSynchronousCommit(t.Context(), SyncCommitRemoteApply))
+
+	// Configuration is reloaded asynchronously
+	reloaded := func() bool {
+		var value string
+
+		err := dbs[0].QueryRowContext(t.Context(), "SHOW synchronous_commit").Scan(&value)
+
+		return err == nil && value == SyncCommitRemoteApply
+	}
+
+	require.Eventually(t, reloaded, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestStreamingReplicationWrongArgs(t *testing.T) {
+	_, err := StartGroup(t.Context(), "17", []string{"pgx5"}, WithStreamingReplication())
+	require.ErrorIs(t, err, ErrStandbysQuantityZero)
+
+	grp := &Group{}
+
+	require.ErrorIs(
+		t,
+		grp.SetSynchronousStandbys(t.Context(), SyncFirst, 1, 1),
+		ErrStreamingDisabled,
+	)
+
+	primary := &node{}
+
+	grp = &Group{
+		nodes: []*node{primary, {primary: primary}},
+		opts: options{
+			streamingReplication: true,
+		},
+	}
+
+	require.ErrorIs(
+		t,
+		grp.SetSynchronousStandbys(t.Context(), "ALL", 1, 1),
+		ErrSyncMethodInvalid,
+	)
+	require.ErrorIs(
+		t,
+		grp.SetSynchronousStandbys(t.Context(), SyncAny, 2, 1),
+		ErrSyncQuantityInvalid,
+	)
+	require.ErrorIs(
+		t,
+		grp.SetSynchronousStandbys(t.Context(), SyncAny, 1, 0),
+		ErrNodeNotStandby,
+	)
+	require.ErrorIs(t, grp.SetSynchronousCommit(t.Context(), "always"), ErrSyncCommitInvalid)
+	require.ErrorIs(t, grp.SetApplyDelay(t.Context(), 1, -time.Second), ErrApplyDelayNegative)
+	require.ErrorIs(t, grp.SetApplyDelay(t.Context(), 0, time.Second), ErrNodeNotStandby)
+	require.ErrorIs(t, grp.WaitLSN(t.Context(), 0, "0/0"), ErrNodeNotStandby)
+}
+
+func TestPrepareHBA(t *testing.T) {
+	require.Equal(
+		t,
+		"local all all trust\n"+
+			"local replication all trust\n"+
+			"hostssl all all all scram-sha-256\n"+
+			"hostssl replication all all scram-sha-256\n",
+		string(prepareHBA(false, false)),
+	)
+
+	require.Equal(
+		t,
+		"local all all trust\n"+
+			"local replication all trust\n"+
+			"hostssl all all all scram-sha-256 clientcert=verify-full\n"+
+			"hostssl replication all all scram-sha-256 clientcert=verify-full\n",
+		string(prepareHBA(true, false)),
+	)
+
+	require.Equal(
+		t,
+		"local all all trust\n"+
+			"local replication all trust\n"+
+			"hostssl all all all scram-sha-256 clientcert=verify-full map=pgbouncer\n"+
+			"hostssl replication all all scram-sha-256 clientcert=verify-full\n",
+		string(prepareHBA(true, true)),
+	)
+}
+
+func TestPrepareIdent(t *testing.T) {
+	roles := []Role{
+		{Name: "reader", Password: "password"},
+		{Name: "readers"},
+	}
+
+	require.Equal(
+		t,
+		`pgbouncer "postgres" "postgres"`+"\n"+
+			`pgbouncer "reader" "reader"`+"\n"+
+			`pgbouncer "postgres" "reader"`+"\n",
+		string(prepareIdent("postgres", roles)),
+	)
+}
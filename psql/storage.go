@@ -0,0 +1,133 @@
+package psql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/hostconfig"
+	"github.com/akramarenkov/illusion/internal/storage"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	ErrTmpfsSizeNotPositive = errors.New("tmpfs size is zero or negative")
+	ErrThrottleDeviceEmpty  = storage.ErrThrottleDeviceEmpty
+	ErrDataDirNotLimited    = errors.New("data directory is not placed on size-limited tmpfs")
+	ErrDataDirNotFilled     = errors.New("data directory was not filled")
+	ErrDataDirNotFreed      = errors.New("data directory was not freed")
+)
+
+// Limits of block I/O of a node. Zero value of a limit means no limit.
+//
+// Limits are applied by the kernel to the specified block device of the host, so
+// the device must be the one on which the data of the node is placed, e.g. the
+// device that holds the data root of the Docker daemon. Write limits affect only
+// direct I/O and writeback accounted by the cgroup of the container.
+type IOThrottle = storage.IOThrottle
+
+// Places the data directory on tmpfs limited to the specified size in bytes (see
+// [WithTmpfs]). Allows to drive a node into running out of disk space, e.g. with
+// the [Group.FillDataDir] method. Cannot be used together with
+// [WithPersistentVolumes].
+//
+// Size must be enough to initialize the database, which takes about 40 MiB.
+func WithTmpfsSize(size int64) Adjuster {
+	adj := func(opts *options) error {
+		if size <= 0 {
+			return ErrTmpfsSizeNotPositive
+		}
+
+		opts.tmpfs = true
+		opts.tmpfsSize = size
+
+		return nil
+	}
+
+	return adj
+}
+
+// Limits block I/O of the nodes with the specified indices. If indices are not
+// specified, limits are used for all nodes for which they are not specified
+// individually.
+func WithIOThrottle(throttle IOThrottle, nodes ...int) Adjuster {
+	adj := func(opts *options) error {
+		return opts.ioThrottles.Set(throttle, nodes, ErrNodeNotFound)
+	}
+
+	return adj
+}
+
+func (grp *Group) tmpfsOptions() string {
+	if grp.opts.tmpfsSize == 0 {
+		return "rw"
+	}
+
+	return "rw,size=" + strconv.FormatInt(grp.opts.tmpfsSize, 10)
+}
+
+// Durability is not needed for the data in memory. Settings are written to the
+// postgresql.auto.conf rather than passed on the command line, which would take
+// precedence over their changes at runtime, e.g. by [Group.SetSynchronousCommit].
+func (n *node) disableDurability(ctx context.Context, target wait.StrategyTarget) error {
+	statements := []string{
+		"ALTER SYSTEM SET fsync = off",
+		"ALTER SYSTEM SET synchronous_commit = off",
+		"SELECT pg_reload_conf()",
+	}
+
+	_, err := query(ctx, target, n.user, maintenanceDatabase, statements...)
+
+	return err
+}
+
+func (grp *Group) prepareNodeThrottle(id int, req *testcontainers.GenericContainerRequest) {
+	throttle := grp.opts.ioThrottles.Node(id)
+	if throttle == nil {
+		return
+	}
+
+	hostconfig.Add(req, func(config *container.HostConfig) {
+		storage.Apply(*throttle, config)
+	})
+}
+
+// Fills the data directory of the node, placed on size-limited tmpfs, with a filler
+// file until there is no space left on it. Space is released by the
+// [Group.FreeDataDir] method.
+func (grp *Group) FillDataDir(ctx context.Context, id int) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFilled, err)
+	}
+
+	if grp.opts.tmpfsSize == 0 {
+		return ErrDataDirNotLimited
+	}
+
+	if _, err := execute.Run(ctx, node.container, storage.FillCommand(grp.opts.dataDir)); err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFilled, err)
+	}
+
+	return nil
+}
+
+// Removes the filler file created by the [Group.FillDataDir] method from the data
+// directory of the node.
+func (grp *Group) FreeDataDir(ctx context.Context, id int) error {
+	node, err := grp.node(id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFreed, err)
+	}
+
+	if _, err := execute.Run(ctx, node.container, storage.FreeCommand(grp.opts.dataDir)); err != nil {
+		return fmt.Errorf("%w: %w", ErrDataDirNotFreed, err)
+	}
+
+	return nil
+}
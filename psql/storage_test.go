@@ -0,0 +1,112 @@
+package psql
+
+import (
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestFillDataDir(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(
+		t.Context(),
+		"17",
+		[]string{"pgx5"},
+		WithTmpfsSize(128<<20),
+	)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	db, err := openDSN(grp.DSNs()[0])
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE filled (payload text)")
+	require.NoError(t, err)
+
+	require.NoError(t, grp.FillDataDir(t.Context(), 0))
+
+	_, err = db.ExecContext(
+		t.Context(),
+		"INSERT INTO filled SELECT repeat('x', 1024) FROM generate_series(1, 10000)",
+	)
+	require.ErrorContains(t, err, "No space left on device")
+
+	require.NoError(t, grp.FreeDataDir(t.Context(), 0))
+
+	_, err = db.ExecContext(
+		t.Context(),
+		"INSERT INTO filled SELECT repeat('x', 1024) FROM generate_series(1, 10000)",
+	)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, grp.FillDataDir(t.Context(), 1), ErrNodeNotFound)
+	require.ErrorIs(t, grp.FreeDataDir(t.Context(), 1), ErrNodeNotFound)
+}
+
+func TestStorageOptions(t *testing.T) {
+	var opts options
+
+	require.ErrorIs(t, WithTmpfsSize(0)(&opts), ErrTmpfsSizeNotPositive)
+	require.ErrorIs(t, WithIOThrottle(IOThrottle{})(&opts), ErrThrottleDeviceEmpty)
+	require.ErrorIs(
+		t,
+		WithIOThrottle(IOThrottle{Device: "/dev/sda"}, -1)(&opts),
+		ErrNodeNotFound,
+	)
+
+	require.NoError(t, WithTmpfsSize(1<<20)(&opts))
+	require.NoError(t, WithIOThrottle(IOThrottle{Device: "/dev/sda", ReadBps: 1})(&opts))
+	require.NoError(t, WithIOThrottle(IOThrottle{Device: "/dev/sdb", WriteIOps: 2}, 1)(&opts))
+
+	grp := &Group{opts: opts}
+
+	require.Equal(t, "rw,size=1048576", grp.tmpfsOptions())
+	require.Equal(t, "rw", (&Group{}).tmpfsOptions())
+
+	var first, second, unlimited testcontainers.GenericContainerRequest
+
+	grp.prepareNodeThrottle(0, &first)
+	grp.prepareNodeThrottle(1, &second)
+	(&Group{}).prepareNodeThrottle(0, &unlimited)
+
+	require.Nil(t, unlimited.HostConfigModifier)
+
+	var config container.HostConfig
+
+	first.HostConfigModifier(&config)
+	second.HostConfigModifier(&config)
+
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sda", Rate: 1}},
+		config.BlkioDeviceReadBps,
+	)
+	require.Equal(
+		t,
+		[]*blkiodev.ThrottleDevice{{Path: "/dev/sdb", Rate: 2}},
+		config.BlkioDeviceWriteIOps,
+	)
+	require.Empty(t, config.BlkioDeviceWriteBps)
+	require.Empty(t, config.BlkioDeviceReadIOps)
+
+	require.NoError(t, WithPersistentVolumes("illusion")(&opts))
+	require.ErrorIs(t, opts.validate(), ErrStorageConflict)
+}
@@ -0,0 +1,364 @@
+package psql
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akramarenkov/illusion/certs"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	authorityCommonName = "illusion"
+
+	// Directory into which certificates are copied before the container starts.
+	// Files in it belong to root, so they are copied to the tlsDir with the
+	// ownership and permissions required by postgres.
+	certsDir = "/etc/postgresql/certs"
+	tlsDir   = "/var/lib/postgresql/tls"
+
+	rootCertFile   = "root.crt"
+	serverCertFile = "server.crt"
+	serverKeyFile  = "server.key"
+	hbaFile        = "pg_hba.conf"
+	identFile      = "pg_ident.conf"
+	identMap       = "pgbouncer"
+	clientCertFile = "client.crt"
+	clientKeyFile  = "client.key"
+	roleCertPrefix = "role-"
+
+	certsFileMode     = 0o644
+	privateKeyMode    = 0o600
+	tempDirNamePrefix = "illusion-psql-"
+)
+
+type tlsEnv struct {
+	authority *certs.Authority
+	client    certs.Pair
+	dir       string
+	hosts     []string
+	// Names of the certificate files of the roles without extension keyed by
+	// names of the roles
+	roles map[string]string
+}
+
+func (grp *Group) prepareTLS(ctx context.Context) error {
+	if !grp.opts.tls {
+		return nil
+	}
+
+	daemonHost, err := getDaemonHost(ctx)
+	if err != nil {
+		return err
+	}
+
+	authority, err := certs.NewAuthority(authorityCommonName, 0)
+	if err != nil {
+		return err
+	}
+
+	client, err := authority.IssueClient(grp.opts.user)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(os.TempDir(), tempDirNamePrefix)
+	if err != nil {
+		return err
+	}
+
+	grp.tls = &tlsEnv{
+		authority: authority,
+		client:    client,
+		dir:       dir,
+		hosts:     []string{daemonHost, "localhost", "127.0.0.1", "::1"},
+		roles:     make(map[string]string),
+	}
+
+	files := map[string][]byte{
+		rootCertFile:   authority.Pair().Cert,
+		clientCertFile: client.Cert,
+		clientKeyFile:  client.Key,
+	}
+
+	// Common name of the client certificate must match the name of the role with
+	// clientcert=verify-full, so each role that can log in gets its own certificate
+	for id, role := range grp.opts.roles {
+		if role.Password == "" {
+			continue
+		}
+
+		pair, err := authority.IssueClient(role.Name)
+		if err != nil {
+			return err
+		}
+
+		// Role names may contain characters that are not allowed in file names
+		name := roleCertPrefix + strconv.Itoa(id)
+
+		grp.tls.roles[role.Name] = name
+		files[name+".crt"] = pair.Cert
+		files[name+".key"] = pair.Key
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, privateKeyMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (grp *Group) removeTLS() error {
+	if grp.tls == nil {
+		return nil
+	}
+
+	if err := os.RemoveAll(grp.tls.dir); err != nil {
+		return err
+	}
+
+	grp.tls = nil
+
+	return nil
+}
+
+func (grp *Group) prepareNodeTLS(hostname string, req *testcontainers.GenericContainerRequest) error {
+	if grp.tls == nil {
+		return nil
+	}
+
+	hosts := append([]string{hostname}, grp.tls.hosts...)
+
+	server, err := grp.tls.authority.IssueServer(hostname, hosts...)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		rootCertFile:   grp.tls.authority.Pair().Cert,
+		serverCertFile: server.Cert,
+		serverKeyFile:  server.Key,
+		hbaFile:        prepareHBA(grp.opts.clientCertAuth, grp.pooledCertAuth()),
+		// Used for connections between nodes
+		clientCertFile: grp.tls.client.Cert,
+		clientKeyFile:  grp.tls.client.Key,
+	}
+
+	if grp.pooledCertAuth() {
+		files[identFile] = prepareIdent(grp.opts.user, grp.opts.roles)
+	}
+
+	for name, data := range files {
+		file := testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(data),
+			ContainerFilePath: certsDir + "/" + name,
+			FileMode:          certsFileMode,
+		}
+
+		// Copies are installed for postgres by root, so the originals can be
+		// readable only by root
+		if name == serverKeyFile || name == clientKeyFile {
+			file.FileMode = privateKeyMode
+		}
+
+		req.Files = append(req.Files, file)
+	}
+
+	return nil
+}
+
+func (grp *Group) tlsSteps() []string {
+	if grp.tls == nil {
+		return nil
+	}
+
+	steps := []string{
+		"install -d -o postgres -g postgres -m 0700 " + tlsDir,
+		"install -o postgres -g postgres -m 0600 " + certsDir + "/* " + tlsDir,
+	}
+
+	return steps
+}
+
+func (grp *Group) tlsSettings(settings map[string]string) {
+	if grp.tls == nil {
+		return
+	}
+
+	settings["ssl"] = "on"
+	settings["ssl_ca_file"] = tlsDir + "/" + rootCertFile
+	settings["ssl_cert_file"] = tlsDir + "/" + serverCertFile
+	settings["ssl_key_file"] = tlsDir + "/" + serverKeyFile
+	settings["hba_file"] = tlsDir + "/" + hbaFile
+
+	if grp.pooledCertAuth() {
+		settings["ident_file"] = tlsDir + "/" + identFile
+	}
+}
+
+// PgBouncer has a single client certificate for server connections, so it is issued
+// for the superuser and mapped to the roles that can log in.
+func (grp *Group) pooledCertAuth() bool {
+	return grp.opts.clientCertAuth && grp.opts.pgBouncer != nil
+}
+
+func (grp *Group) tlsQuery() url.Values {
+	if grp.tls == nil {
+		return url.Values{"sslmode": []string{"disable"}}
+	}
+
+	query := url.Values{
+		"sslmode":     []string{"verify-full"},
+		"sslrootcert": []string{filepath.Join(grp.tls.dir, rootCertFile)},
+		"sslcert":     []string{filepath.Join(grp.tls.dir, clientCertFile)},
+		"sslkey":      []string{filepath.Join(grp.tls.dir, clientKeyFile)},
+	}
+
+	return query
+}
+
+// Returns TLS parameters of the DSN for connecting as the role.
+func (grp *Group) tlsRoleQuery(name string) url.Values {
+	query := grp.tlsQuery()
+
+	if grp.tls == nil {
+		return query
+	}
+
+	if file, exists := grp.tls.roles[name]; exists {
+		query.Set("sslcert", filepath.Join(grp.tls.dir, file+".crt"))
+		query.Set("sslkey", filepath.Join(grp.tls.dir, file+".key"))
+	}
+
+	return query
+}
+
+// Returns parameters of connection between nodes in the form of libpq connection
+// string parameters.
+func (grp *Group) tlsConnParams() map[string]string {
+	if grp.tls == nil {
+		return map[string]string{"sslmode": "disable"}
+	}
+
+	params := map[string]string{
+		"sslmode":     "verify-full",
+		"sslrootcert": tlsDir + "/" + rootCertFile,
+		"sslcert":     tlsDir + "/" + clientCertFile,
+		"sslkey":      tlsDir + "/" + clientKeyFile,
+	}
+
+	return params
+}
+
+func prepareHBA(clientCertAuth bool, mapped bool) []byte {
+	// Local connections are used by the entrypoint of the image during
+	// initialization and by the commands executed in the container
+	hba := "local all all trust\nlocal replication all trust\n"
+
+	auth := "scram-sha-256"
+
+	if clientCertAuth {
+		auth += " clientcert=verify-full"
+	}
+
+	if mapped {
+		hba += "hostssl all all all " + auth + " map=" + identMap + "\n"
+	} else {
+		hba += "hostssl all all all " + auth + "\n"
+	}
+
+	hba += "hostssl replication all all " + auth + "\n"
+
+	return []byte(hba)
+}
+
+// Common name of the client certificate is checked against the map instead of the
+// role name, so each role is mapped to itself and the certificate of the superuser
+// is mapped to each role.
+func prepareIdent(superuser string, roles []Role) []byte {
+	entry := func(certName string, role string) string {
+		return identMap + " " + quoteIdentifier(certName) + " " + quoteIdentifier(role) + "\n"
+	}
+
+	ident := entry(superuser, superuser)
+
+	for _, role := range roles {
+		if role.Password == "" {
+			continue
+		}
+
+		ident += entry(role.Name, role.Name)
+		ident += entry(superuser, role.Name)
+	}
+
+	return []byte(ident)
+}
+
+// Returns the hook that copies the private key to the created container with
+// permissions that allow only the user of the container to read it. Unlike
+// [testcontainers.ContainerFile], which always belongs to root, the key is owned by
+// the user of the container, so it can be read by the process that does not run
+// as root.
+func copyPrivateKey(path string, key []byte) testcontainers.ContainerHook {
+	hook := func(ctx context.Context, ctr testcontainers.Container) error {
+		var archive bytes.Buffer
+
+		writer := tar.NewWriter(&archive)
+
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     strings.TrimPrefix(path, "/"),
+			Mode:     privateKeyMode,
+			Size:     int64(len(key)),
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if _, err := writer.Write(key); err != nil {
+			return err
+		}
+
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		client, err := testcontainers.NewDockerClientWithOpts(ctx)
+		if err != nil {
+			return err
+		}
+
+		defer client.Close()
+
+		options := container.CopyToContainerOptions{
+			CopyUIDGID: true,
+		}
+
+		return client.CopyToContainer(ctx, ctr.GetContainerID(), "/", &archive, options)
+	}
+
+	return hook
+}
+
+func getDaemonHost(ctx context.Context) (string, error) {
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return "", fmt.Errorf("creating docker provider: %w", err)
+	}
+
+	defer provider.Close()
+
+	return provider.DaemonHost(ctx)
+}
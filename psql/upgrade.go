@@ -0,0 +1,349 @@
+package psql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/akramarenkov/illusion/internal/execute"
+	"github.com/akramarenkov/illusion/internal/hostconfig"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Methods of the major version upgrade.
+type UpgradeMethod int
+
+const (
+	// Data directory is upgraded by pg_upgrade in a helper container that contains
+	// binaries of both versions. Image tianon/postgres-upgrade is used as a helper,
+	// so only nodes that use the postgres image without additional extensions can be
+	// upgraded by this method.
+	UpgradePgUpgrade UpgradeMethod = iota
+
+	// All databases are dumped by pg_dumpall and restored on the new version
+	// started with a new data directory. Errors of restoring objects that are already
+	// created by the image of the new version, such as the superuser and the
+	// database of the group, are ignored.
+	UpgradeDumpRestore
+)
+
+const (
+	upgradeHelperImage = "tianon/postgres-upgrade"
+	upgradeOldDataDir  = "/var/lib/postgresql/old"
+	upgradeNewDataDir  = "/var/lib/postgresql/new"
+	upgradeDumpFile    = "/tmp/illusion-upgrade.sql"
+)
+
+var (
+	ErrNodeNotUpgraded       = errors.New("node was not upgraded")
+	ErrUpgradeMethodInvalid  = errors.New("upgrade method is invalid")
+	ErrUpgradeNotSupported   = errors.New("upgrade is not supported for nodes with tmpfs or persistent volumes")
+	ErrVersionNotGreater     = errors.New("new major version is not greater than the current one")
+	ErrVersionNotRecognized  = errors.New("major version is not recognized in the image tag")
+	ErrDataVolumeNotFound    = errors.New("volume of the data directory was not found")
+	ErrUpgradeHelperExitCode = errors.New("upgrade helper exited with non-zero code")
+)
+
+var majorVersionRegexp = regexp.MustCompile(`\d+`) //nolint:gochecknoglobals // Compiled once
+
+// Upgrades the node with the specified index to the major version of the specified
+// image tag. The node is stopped, its data is upgraded using the specified method
+// and the node is started on the new version with the same hostname. Returns the
+// updated DSN of the node, which is also returned by the [Group.DSNs] method.
+//
+// Major versions are recognized by the first number in the image tags.
+func (grp *Group) Upgrade(ctx context.Context, id int, imageTag string, method UpgradeMethod) (url.URL, error) {
+	node, err := grp.node(id)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	if err := grp.upgrade(ctx, node, imageTag, method); err != nil {
+		return url.URL{}, fmt.Errorf("%w: %w", ErrNodeNotUpgraded, err)
+	}
+
+	host, err := node.container.Host(ctx)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	port, err := node.container.MappedPort(ctx, sqlPortTCP)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	grp.dsns[id] = grp.prepareDSN(node, net.JoinHostPort(host, port.Port()))
+
+	return grp.dsns[id], nil
+}
+
+func (grp *Group) upgrade(ctx context.Context, node *node, imageTag string, method UpgradeMethod) error {
+	if grp.opts.tmpfs || grp.opts.volumesPrefix != "" {
+		return ErrUpgradeNotSupported
+	}
+
+	current, err := parseMajorVersion(node.imageTag)
+	if err != nil {
+		return err
+	}
+
+	target, err := parseMajorVersion(imageTag)
+	if err != nil {
+		return err
+	}
+
+	if target <= current {
+		return fmt.Errorf("%w: %d, %d", ErrVersionNotGreater, current, target)
+	}
+
+	switch method {
+	case UpgradePgUpgrade:
+		return grp.upgradeByPgUpgrade(ctx, node, imageTag, current, target)
+	case UpgradeDumpRestore:
+		return grp.upgradeByDumpRestore(ctx, node, imageTag)
+	}
+
+	return fmt.Errorf("%w: %d", ErrUpgradeMethodInvalid, method)
+}
+
+func (grp *Group) upgradeByPgUpgrade(
+	ctx context.Context,
+	node *node,
+	imageTag string,
+	current int,
+	target int,
+) error {
+	oldVolume, err := grp.findDataVolume(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	newVolume, err := prepareHostname()
+	if err != nil {
+		return err
+	}
+
+	grp.volumes = append(grp.volumes, newVolume)
+
+	if err := node.container.Stop(ctx, nil); err != nil {
+		return err
+	}
+
+	if err := grp.runUpgradeHelper(ctx, node, oldVolume, newVolume, current, target); err != nil {
+		return err
+	}
+
+	request := node.req
+	request.Image = grp.opts.image + ":" + imageTag
+	request.Mounts = nil
+
+	hostconfig.Add(&request, func(config *container.HostConfig) {
+		volume := mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: newVolume,
+			Target: grp.opts.dataDir,
+		}
+
+		config.Mounts = append(config.Mounts, volume)
+	})
+
+	return grp.restartNode(ctx, node, imageTag, request)
+}
+
+func (grp *Group) runUpgradeHelper(
+	ctx context.Context,
+	node *node,
+	oldVolume string,
+	newVolume string,
+	current int,
+	target int,
+) error {
+	oldBin := "/usr/lib/postgresql/" + strconv.Itoa(current) + "/bin"
+	newBin := "/usr/lib/postgresql/" + strconv.Itoa(target) + "/bin"
+
+	steps := slices.Concat([]string{"set -e"}, node.locale.steps())
+
+	script := strings.Join(steps, " && ") + " && " +
+		"chown postgres:postgres " + upgradeNewDataDir + " && " +
+		"chmod 0700 " + upgradeNewDataDir + " && " +
+		"cd /var/lib/postgresql && " +
+		"gosu postgres " + newBin + "/initdb --pgdata " + upgradeNewDataDir +
+		" --username " + quoteShell(node.user) + " " + grp.nodeInitdbArgs(node) + " && " +
+		"gosu postgres " + newBin + "/pg_upgrade" +
+		" --old-bindir " + oldBin + " --new-bindir " + newBin +
+		" --old-datadir " + upgradeOldDataDir + " --new-datadir " + upgradeNewDataDir +
+		" --username " + quoteShell(node.user) + " && " +
+		"cp " + upgradeOldDataDir + "/pg_hba.conf " + upgradeNewDataDir + "/pg_hba.conf"
+
+	request := testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image: upgradeHelperImage + ":" + strconv.Itoa(current) + "-to-" + strconv.Itoa(target),
+			Entrypoint: []string{
+				"sh",
+				"-c",
+				script,
+			},
+			Mounts: testcontainers.Mounts(
+				testcontainers.VolumeMount(oldVolume, upgradeOldDataDir),
+				testcontainers.VolumeMount(newVolume, upgradeNewDataDir),
+			),
+			WaitingFor: wait.ForExit().WithExitTimeout(grp.opts.startupTimeout),
+		},
+		Started: true,
+	}
+
+	// Volume with the upgraded data is created for the helper
+	grp.prepareKeep(&request)
+
+	helper, err := testcontainers.GenericContainer(ctx, request)
+	if err != nil {
+		return errors.Join(err, testcontainers.TerminateContainer(helper))
+	}
+
+	state, err := helper.State(ctx)
+	if err != nil {
+		return errors.Join(err, testcontainers.TerminateContainer(helper))
+	}
+
+	if state.ExitCode != 0 {
+		logs, err := readLogs(ctx, helper)
+
+		return errors.Join(
+			fmt.Errorf("%w: %d: %s", ErrUpgradeHelperExitCode, state.ExitCode, logs),
+			err,
+			testcontainers.TerminateContainer(helper),
+		)
+	}
+
+	return testcontainers.TerminateContainer(helper)
+}
+
+func (grp *Group) upgradeByDumpRestore(ctx context.Context, node *node, imageTag string) error {
+	dump := []string{
+		"pg_dumpall",
+		"--username", node.user,
+		"--file", upgradeDumpFile,
+	}
+
+	if _, err := execute.Run(ctx, node.container, dump); err != nil {
+		return fmt.Errorf("dumping: %w", err)
+	}
+
+	reader, err := node.container.CopyFileFromContainer(ctx, upgradeDumpFile)
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	request := node.req
+	request.Image = grp.opts.image + ":" + imageTag
+
+	if err := grp.restartNode(ctx, node, imageTag, request); err != nil {
+		return err
+	}
+
+	if err := node.container.CopyToContainer(ctx, data, upgradeDumpFile, certsFileMode); err != nil {
+		return err
+	}
+
+	restore := []string{
+		"psql",
+		"--no-psqlrc",
+		"--quiet",
+		"--username", node.user,
+		"--dbname", maintenanceDatabase,
+		"--file", upgradeDumpFile,
+	}
+
+	if _, err := execute.Run(ctx, node.container, restore); err != nil {
+		return fmt.Errorf("restoring: %w", err)
+	}
+
+	return nil
+}
+
+// Replaces the container of the node with a new one created from the specified
+// request.
+func (grp *Group) restartNode(
+	ctx context.Context,
+	node *node,
+	imageTag string,
+	request testcontainers.GenericContainerRequest,
+) error {
+	if err := testcontainers.TerminateContainer(node.container); err != nil {
+		return err
+	}
+
+	node.imageTag = imageTag
+	node.req = request
+
+	created, err := testcontainers.GenericContainer(ctx, request)
+
+	// Container can be created but not running, in which case an error will also be
+	// received. However, the container must be removed
+	node.container = created
+
+	return err
+}
+
+func (grp *Group) findDataVolume(ctx context.Context, node *node) (string, error) {
+	info, err := node.container.Inspect(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, mount := range info.Mounts {
+		if mount.Destination == grp.opts.dataDir && mount.Name != "" {
+			return mount.Name, nil
+		}
+	}
+
+	return "", ErrDataVolumeNotFound
+}
+
+func parseMajorVersion(imageTag string) (int, error) {
+	found := majorVersionRegexp.FindString(imageTag)
+	if found == "" {
+		return 0, fmt.Errorf("%w: %s", ErrVersionNotRecognized, imageTag)
+	}
+
+	return strconv.Atoi(found)
+}
+
+func readLogs(ctx context.Context, ctr testcontainers.Container) ([]byte, error) {
+	reader, err := ctr.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	logs, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(logs), nil
+}
+
+func quoteShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,89 @@
+package psql
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/akramarenkov/illusion/internal/interceptor"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgrade(t *testing.T) {
+	shutdown, err := interceptor.Run()
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, shutdown(t.Context()))
+	}()
+
+	grp, err := StartGroup(t.Context(), "16", []string{"pgx5", "pgx5"})
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, grp.Cleanup(t.Context(), t))
+	}()
+
+	for id, method := range []UpgradeMethod{UpgradePgUpgrade, UpgradeDumpRestore} {
+		dsn := grp.DSNs()[id]
+
+		db, err := sql.Open("pgx", dsn.String())
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(t.Context(), "CREATE TABLE kept (value integer)")
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(t.Context(), "INSERT INTO kept VALUES (1), (2)")
+		require.NoError(t, err)
+
+		require.NoError(t, db.Close())
+
+		upgraded, err := grp.Upgrade(t.Context(), id, "17", method)
+		require.NoError(t, err)
+		require.Equal(t, upgraded, grp.DSNs()[id])
+
+		db, err = sql.Open("pgx", upgraded.String())
+		require.NoError(t, err)
+
+		var version int
+
+		require.NoError(
+			t,
+			db.QueryRowContext(
+				t.Context(),
+				"SELECT current_setting('server_version_num')::integer / 10000",
+			).Scan(&version),
+		)
+		require.Equal(t, 17, version)
+
+		var sum int
+
+		require.NoError(t, db.QueryRowContext(t.Context(), "SELECT sum(value) FROM kept").Scan(&sum))
+		require.Equal(t, 3, sum)
+
+		require.NoError(t, db.Close())
+	}
+
+	_, err = grp.Upgrade(t.Context(), 0, "16", UpgradeDumpRestore)
+	require.Error(t, err)
+
+	_, err = grp.Upgrade(t.Context(), 2, "18", UpgradeDumpRestore)
+	require.Error(t, err)
+}
+
+func TestParseMajorVersion(t *testing.T) {
+	version, err := parseMajorVersion("17")
+	require.NoError(t, err)
+	require.Equal(t, 17, version)
+
+	version, err = parseMajorVersion("16.4-bookworm")
+	require.NoError(t, err)
+	require.Equal(t, 16, version)
+
+	version, err = parseMajorVersion("pg15")
+	require.NoError(t, err)
+	require.Equal(t, 15, version)
+
+	_, err = parseMajorVersion("latest")
+	require.Error(t, err)
+}